@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/gallyamow/go-crawler/internal"
+	"github.com/gallyamow/go-crawler/pkg/dnscache"
 	"github.com/gallyamow/go-crawler/pkg/fanin"
 	"github.com/gallyamow/go-crawler/pkg/httpclient"
 	"github.com/gallyamow/go-crawler/pkg/retry"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -42,17 +48,150 @@ func main() {
 		logger.Error("Failed to parse startURL", "err", err, "value", config.URL)
 		os.Exit(1)
 	}
+	output, err := safefs.Open(config.OutputDir)
+	if err != nil {
+		logger.Error("Failed to open output dir", "err", err, "path", config.OutputDir)
+		os.Exit(1)
+	}
+	defer output.Close()
+
+	pathIndexFile := filepath.Join(config.StateDir(), "paths.json")
+	paths, err := internal.LoadPathMapper(pathIndexFile)
+	if err != nil {
+		logger.Error("Failed to load path index", "err", err, "path", pathIndexFile)
+		os.Exit(1)
+	}
+
+	// previous crawl results for conditional requests, nil disables them
+	var fetchIndex *internal.FetchIndex
+	if config.Incremental {
+		if fetchIndex, err = internal.LoadFetchIndex(config.StateDir(), output); err != nil {
+			logger.Error("Failed to load fetch index", "err", err, "path", config.StateDir())
+			os.Exit(1)
+		}
+	}
+
+	// identical assets are stored once, nil saves every file separately
+	var blobs *internal.BlobStore
+	if config.Dedup != internal.DedupModeOff {
+		blobs = internal.NewBlobStore(output, config.Dedup)
+	}
+
+	// pages already crawled under another url become aliases, nil crawls every page
+	var pageDedup *internal.PageDedup
+	if config.PageDedup != internal.PageDedupOff {
+		pageDedup = internal.NewPageDedup(config.PageDedup)
+	}
+
+	// pages with almost the same text are listed in the report, nil compares nothing
+	var nearDedup *internal.NearDedup
+	if config.NearDupDistance > 0 {
+		nearDedup = internal.NewNearDedup(config.NearDupDistance)
+	}
+
+	// files of every crawl are tracked even without sync, so that a later sync finds them
+	mirrorSync, err := internal.LoadMirrorSync(config.StateDir())
+	if err != nil {
+		logger.Error("Failed to load sync index", "err", err, "path", config.StateDir())
+		os.Exit(1)
+	}
+
+	startPage.Mirror = &internal.MirrorOptions{
+		SRIPolicy: config.SRIPolicy,
+		Paths:     paths,
+	}
+
+	metrics := internal.NewMetrics()
+
+	// every connection, including redirects, is checked against the resolved address
+	dialGuard := httpclient.NewDialGuard(config.AllowPrivateNetworks, config.DenyCIDRs, config.AllowCIDRs)
 
-	var httpPool = &sync.Pool{
-		New: func() any {
-			return httpclient.NewClient(httpclient.WithTimeout(config.Timeout))
-		},
+	transportConfig := config.TransportConfig()
+	transportConfig.DialGuard = dialGuard
+	// validated on config load
+	transportConfig.Proxy, _ = config.ProxyRouter()
+	if config.DNSCacheTTL > 0 || len(config.Resolve) > 0 {
+		transportConfig.Resolver = dnscache.New(config.DNSCacheTTL, dnscache.WithOverrides(config.Resolve))
+	}
+	if transportConfig.TLS, err = config.TLSConfig(); err != nil {
+		logger.Error("Failed to load TLS configuration", "err", err)
+		os.Exit(1)
+	}
+	for _, host := range config.TLSHosts {
+		if host.InsecureSkipVerify {
+			logger.Warn("TLS CERTIFICATE VERIFICATION IS DISABLED, responses can be forged", "host", host.Host)
+		}
+	}
+
+	middlewares := []httpclient.Middleware{
+		httpclient.LoggingMiddleware(logger),
+		httpclient.MetricsMiddleware(metrics),
+	}
+	if len(config.Headers) > 0 {
+		headers := http.Header{}
+		for name, value := range config.Headers {
+			headers.Set(name, value)
+		}
+		middlewares = append(middlewares, httpclient.HeadersMiddleware(headers))
+	}
+	if len(config.Auth) > 0 {
+		middlewares = append(middlewares, httpclient.HostAuthMiddleware(config.Auth))
+	}
+	// the cache is inside headers and auth to match Vary against the final request and to see the credentials,
+	// cache hits are not rate limited
+	if config.CacheDir != "" {
+		middlewares = append(middlewares, httpclient.HTTPCacheMiddleware(httpclient.NewDiskCache(config.CacheDir), config.CacheOnly))
+	}
+	if config.RateLimit > 0 {
+		middlewares = append(middlewares, httpclient.RateLimitMiddleware(config.RateLimit))
+	}
+
+	clientOptions := []httpclient.OptionFunc{
+		httpclient.WithTransport(httpclient.NewTransport(transportConfig)),
+		httpclient.WithMiddleware(middlewares...),
+		httpclient.WithBodyTimeout(config.BodyTimeout),
+		httpclient.WithTimeout(config.Timeout),
+		httpclient.WithLimits(httpclient.Limits{
+			MaxBodySize:   config.MaxFileSize,
+			MaxRatio:      config.MaxDecompressionRatio,
+			MinRate:       config.MinTransferRate,
+			MaxHeaderSize: config.MaxHeaderSize,
+		}),
+	}
+
+	// login keeps the session in cookies, so it needs a jar even if it is not persisted
+	var cookieJar *httpclient.Jar
+	if config.CookieJar != "" || config.Login != nil {
+		if cookieJar, err = httpclient.NewJar(); err == nil && config.CookieJar != "" {
+			err = cookieJar.Load(config.CookieJar)
+		}
+		if err != nil {
+			logger.Error("Failed to load cookies", "err", err, "path", config.CookieJar)
+			os.Exit(1)
+		}
+		clientOptions = append(clientOptions, httpclient.WithCookieJar(cookieJar))
+	}
+
+	// @idiomatic: one client (and one transport) for all workers, otherwise connections are not reused
+	httpClient := httpclient.NewClient(clientOptions...)
+
+	var login *internal.Login
+	if config.Login != nil {
+		if login, err = internal.NewLogin(config.Login, httpClient, cookieJar); err == nil {
+			err = login.Run(ctx)
+		}
+		if err != nil {
+			logger.Error("Failed to log in", "err", err, "url", config.Login.URL)
+			os.Exit(1)
+		}
+		logger.Info("Logged in", "url", config.Login.URL)
 	}
 
 	// Размеры буферов будем рассчитывать на этой основе
 	maxConcurrent := config.MaxConcurrent
 
 	queue := internal.NewQueue(ctx, config.MaxCount, maxConcurrent, logger)
+	report := internal.NewReport()
 
 	// @idiomatic: используем буферизированные каналы разных размеров и разное кол-во workers, чтобы регулировать back pressure.
 	// На практике bufferSize = workersCnt - часто недостаточно. Обычно используют x2, x4 - ПЕРЕД медленным.
@@ -66,26 +205,32 @@ func main() {
 				ctx,
 				queue.Pages(),
 				maxConcurrent, maxConcurrent*2,
-				config, httpPool, logger,
+				config, httpClient, login, fetchIndex, pageDedup, metrics, report, logger,
 			),
 			maxConcurrent, maxConcurrent*2,
-			queue, config, logger,
+			queue, pageDedup, nearDedup, report, config, logger,
 		),
 		maxConcurrent, maxConcurrent*2,
-		config,
+		output, blobs, fetchIndex, mirrorSync, report, config,
 		logger,
 	)
 
+	// assets are mostly not parsable, but some of them (web app manifests) refer to other assets
 	assetsCh := saveStage(
 		ctx,
-		downloadStage(
+		parseStage(
 			ctx,
-			queue.Assets(),
+			downloadStage(
+				ctx,
+				queue.Assets(),
+				maxConcurrent, maxConcurrent*2,
+				config, httpClient, login, fetchIndex, pageDedup, metrics, report, logger,
+			),
 			maxConcurrent, maxConcurrent*2,
-			config, httpPool, logger,
+			queue, pageDedup, nearDedup, report, config, logger,
 		),
 		maxConcurrent, maxConcurrent*2,
-		config,
+		output, blobs, fetchIndex, mirrorSync, report, config,
 		logger,
 	)
 
@@ -93,14 +238,22 @@ func main() {
 	queue.Push(startPage)
 
 	var pagesCnt, assetsCnt = 0, 0
+	var integrityPages []string
+	relinker := internal.NewRelinker()
 
 	// @idiomatic: using fan-in to merge channels instead of using for + flags
 	for item := range fanin.Merge(pagesCh, assetsCh) {
+		relinker.Add(item)
+
 		switch item.(type) {
 		case *internal.Page:
 			pagesCnt++
 			queue.Ack(item)
 
+			if page := item.(*internal.Page); config.SRIPolicy == internal.SRIPolicyRecompute && page.SkippedOn == "" && page.DuplicateOf == nil && page.HasIntegrity() {
+				integrityPages = append(integrityPages, page.ResolveRelativeSavePath())
+			}
+
 			logger.Info(fmt.Sprintf("Done for page %d of %d", pagesCnt, config.MaxCount))
 		default:
 			assetsCnt++
@@ -111,20 +264,114 @@ func main() {
 		}
 	}
 
+	// the type of a linked item is known only after its download, which could be after the page referring to it is saved
+	for _, pagePath := range relinker.Pages() {
+		if err := relinker.RelinkPage(output, pagePath); err != nil {
+			logger.Error("Failed to rewrite links", "err", err, "path", pagePath)
+		}
+	}
+
+	// assets could be saved after pages which refer to them, so hashes are recomputed only when everything is saved
+	for _, pagePath := range integrityPages {
+		if err := internal.RecomputeIntegrity(output, pagePath); err != nil {
+			logger.Error("Failed to recompute integrity", "err", err, "path", pagePath)
+		}
+	}
+
+	if nearDedup != nil {
+		report.RecordNearDuplicates(nearDedup.Clusters())
+	}
+
+	if err := paths.Save(pathIndexFile); err != nil {
+		logger.Error("Failed to save path index", "err", err, "path", pathIndexFile)
+	}
+
+	// only a complete crawl knows which files are gone upstream
+	complete := ctx.Err() == nil && !queue.Truncated()
+	if config.Sync != internal.SyncModeOff && complete {
+		record := mirrorSync.Sync(output, internal.SyncOptions{Mode: config.Sync, DryRun: config.SyncDryRun, MaxRatio: config.SyncMaxRatio}, startedAt)
+		report.RecordSync(record)
+
+		for _, path := range record.Stale {
+			logger.Info("Stale file", "path", path, "dry_run", config.SyncDryRun)
+		}
+		for _, syncErr := range record.Errors {
+			logger.Warn("Failed to sync file", "url", syncErr.URL, "err", syncErr.Message)
+		}
+		if record.Refused != "" {
+			logger.Error("Sync refused, nothing is removed", "reason", record.Refused)
+		}
+
+		// removed items are downloaded again if they come back
+		if fetchIndex != nil {
+			for _, url := range record.URLs {
+				fetchIndex.Forget(url)
+			}
+		}
+
+		logger.Info("Sync completed", "mode", config.Sync, "dry_run", config.SyncDryRun, "stale", len(record.Stale), "removed", len(record.Removed))
+	} else {
+		if config.Sync != internal.SyncModeOff {
+			logger.Warn("Sync skipped: the crawl is incomplete (interrupted or stopped by max-count)")
+		}
+		mirrorSync.KeepUnreached()
+	}
+
+	if err := mirrorSync.Save(); err != nil {
+		logger.Error("Failed to save sync index", "err", err, "path", config.StateDir())
+	}
+
+	if fetchIndex != nil {
+		if err := fetchIndex.Save(); err != nil {
+			logger.Error("Failed to save fetch index", "err", err, "path", config.StateDir())
+		}
+	}
+
+	if cookieJar != nil {
+		if err := cookieJar.Save(config.CookieJar); err != nil {
+			logger.Error("Failed to save cookies", "err", err, "path", config.CookieJar)
+		}
+	}
+
 	msg := "Crawling completed"
 	if ctx.Err() != nil {
 		msg = "Crawling interrupted"
 	}
 
+	stats := metrics.GetStats()
 	logger.Info(
 		msg,
 		"elapsed", time.Since(startedAt).String(),
 		"pages_crawled", pagesCnt,
 		"assets_crawled", assetsCnt,
+		"redirects", len(report.Redirects),
+		"page_aliases", len(report.PageAliases),
+		"near_duplicate_clusters", len(report.NearDuplicates),
+		"integrity_mismatches", len(report.IntegrityMismatches),
+		"bytes_downloaded", stats.BytesDownloaded,
+		"bytes_decoded", stats.BytesDecoded,
+		"not_modified", stats.NotModified,
 	)
+
+	if blobs != nil {
+		dedupStats := blobs.Stats()
+		report.RecordDedup(config.Dedup, dedupStats)
+		logger.Info("Dedup", "mode", config.Dedup, "files", dedupStats.Files, "blobs", dedupStats.Blobs, "bytes", dedupStats.Bytes, "stored_bytes", dedupStats.StoredBytes, "ratio", fmt.Sprintf("%.2f", dedupStats.Ratio()))
+	}
+
+	if transportConfig.Resolver != nil {
+		dnsStats := transportConfig.Resolver.Stats()
+		logger.Info("DNS cache", "hits", dnsStats.Hits, "misses", dnsStats.Misses, "errors", dnsStats.Errors, "overrides", dnsStats.Overrides, "hosts", dnsStats.Entries)
+	}
+
+	if config.ReportFile != "" {
+		if err := report.Save(config.ReportFile); err != nil {
+			logger.Error("Failed to save report", "err", err, "path", config.ReportFile)
+		}
+	}
 }
 
-func downloadStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt int, bufferSize int, config *internal.Config, httpClientPool *sync.Pool, logger *slog.Logger) chan internal.Queueable {
+func downloadStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt int, bufferSize int, config *internal.Config, httpClient *httpclient.Client, login *internal.Login, fetchIndex *internal.FetchIndex, pageDedup *internal.PageDedup, metrics *internal.Metrics, report *internal.Report, logger *slog.Logger) chan internal.Queueable {
 	outCh := make(chan internal.Queueable, bufferSize)
 
 	var wg sync.WaitGroup
@@ -148,18 +395,63 @@ func downloadStage(ctx context.Context, inCh <-chan internal.Queueable, workersC
 
 					downloadableItem := item.(internal.Downloadable)
 					size, err := retry.Retry[int](ctx, func() (int, error) {
-						downloadErr := downloadItem(ctx, downloadableItem, config, httpClientPool)
+						downloadErr := downloadItem(ctx, downloadableItem, config, httpClient, login, fetchIndex, metrics, report)
 						if downloadErr != nil {
 							return 0, downloadErr
 						}
 						return downloadableItem.GetSize(), nil
-					}, retry.NewConfig(retry.WithMaxAttempts(config.RetryAttempts), retry.WithDelay(config.RetryDelay)))
-
-					if err != nil {
+					}, retry.NewConfig(
+						retry.WithMaxAttempts(config.RetryAttempts),
+						retry.WithDelay(config.RetryDelay),
+						retry.WithRetryableChecker(isRetryableDownloadErr),
+					))
+
+					var rejection *internal.FilterRejection
+					var blocked *httpclient.BlockedAddressError
+					var limitErr *httpclient.LimitError
+					var notCached *httpclient.NotCachedError
+					if errors.As(err, &rejection) {
+						logger.Debug(fmt.Sprintf("Item '%s' downloading skipped: %v.", logId, err))
+						report.RecordRejection(logId, rejection)
+						item.SetSkipped("download")
+					} else if errors.As(err, &blocked) {
+						logger.Warn(fmt.Sprintf("Item '%s' downloading skipped: %v.", logId, err))
+						report.RecordBlockedAddress(logId, blocked)
+						item.SetSkipped("download")
+					} else if errors.As(err, &limitErr) {
+						logger.Warn(fmt.Sprintf("Item '%s' downloading skipped: %v.", logId, err))
+						report.RecordLimitViolation(logId, limitErr)
+						item.SetSkipped("download")
+					} else if errors.As(err, &notCached) {
+						logger.Debug(fmt.Sprintf("Item '%s' downloading skipped: %v.", logId, err))
+						item.SetSkipped("download")
+					} else if err != nil {
 						logger.Debug(fmt.Sprintf("Item '%s' downloading skipped, after %d attempts, with error: %v.", logId, config.RetryAttempts, err))
 						item.SetSkipped("download")
 					} else {
 						logger.Debug(fmt.Sprintf("Item '%s' downloaded, size %d bytes.", logId, size))
+
+						// links are queued as pages, but they could refer to pdf, images and so on
+						if classifiable, ok := item.(internal.Classifiable); ok {
+							item = classifiable.Classify()
+							if _, ok := item.(*internal.Page); !ok {
+								logger.Debug(fmt.Sprintf("Item '%s' is not a html page, it will be saved as is.", logId))
+							}
+						}
+
+						if page, ok := item.(*internal.Page); ok && pageDedup != nil && pageDedup.ByContent(page) {
+							logger.Debug(fmt.Sprintf("Item '%s' has the same content as '%s', it will be saved as an alias.", logId, page.DuplicateOf))
+							report.RecordPageAlias(logId, page.DuplicateOf.String(), internal.PageAliasContent)
+						}
+
+						// mismatched item is saved anyway, it is up to SRIPolicy how it will be loaded
+						// (not modified assets have no content, they were verified by the previous crawl)
+						if verifiable, ok := item.(internal.Verifiable); ok && !isNotModified(item) {
+							if verifyErr := verifiable.VerifyIntegrity(); verifyErr != nil {
+								logger.Warn(fmt.Sprintf("Item '%s' failed integrity check: %v.", logId, verifyErr))
+								report.RecordIntegrityMismatch(logId, verifyErr)
+							}
+						}
 					}
 
 					select {
@@ -181,7 +473,7 @@ func downloadStage(ctx context.Context, inCh <-chan internal.Queueable, workersC
 	return outCh
 }
 
-func parseStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt int, bufferSize int, queue *internal.Queue, config *internal.Config, logger *slog.Logger) chan internal.Queueable {
+func parseStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt int, bufferSize int, queue *internal.Queue, pageDedup *internal.PageDedup, nearDedup *internal.NearDedup, report *internal.Report, config *internal.Config, logger *slog.Logger) chan internal.Queueable {
 	outCh := make(chan internal.Queueable, bufferSize)
 
 	var wg sync.WaitGroup
@@ -204,7 +496,8 @@ func parseStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt
 					logId := item.(internal.Queueable).ItemId()
 					logger.Debug(fmt.Sprintf("Item '%s' received by the 'parse' stage", logId))
 
-					if parsable, ok := item.(internal.Parsable); ok {
+					// a failed download has nothing to parse, its skip stage is kept for the 'save' stage
+					if parsable, ok := item.(internal.Parsable); ok && item.GetSkippedOn() != "download" {
 						err := parsable.Parse()
 						if err != nil {
 							logger.Debug(fmt.Sprintf("Item '%s' parsing skipped, with error: %v.", logId, err))
@@ -213,6 +506,22 @@ func parseStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt
 							logger.Debug(fmt.Sprintf("Item '%s' parsed, found child items %d", logId, len(parsable.GetChildren())))
 						}
 
+						if page, ok := item.(*internal.Page); ok {
+							for _, redirect := range page.GetRedirects() {
+								report.RecordRedirect(page.GetURL(), redirect.URL.String(), redirect.Redirect)
+							}
+
+							// a duplicate has no children, they are queued by its primary page (a failed page cannot be primary)
+							if err == nil && page.SkippedOn == "" && pageDedup != nil && pageDedup.ByCanonical(page) {
+								logger.Debug(fmt.Sprintf("Item '%s' has the same canonical url as '%s', it will be saved as an alias.", logId, page.DuplicateOf))
+								report.RecordPageAlias(logId, page.DuplicateOf.String(), internal.PageAliasCanonical)
+							}
+
+							if err == nil && page.SkippedOn == "" && page.DuplicateOf == nil && nearDedup != nil && nearDedup.Check(page) {
+								logger.Debug(fmt.Sprintf("Item '%s' is a near-duplicate of '%s'.", logId, page.NearDuplicateOf))
+							}
+						}
+
 						// @idiomatic: check context before long-running operations
 						if ctx.Err() != nil {
 							return
@@ -227,6 +536,10 @@ func parseStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt
 						// Решение: 1) Вынести в буфер и добавлять его на последней стадии. Но тогда будет просто блокироваться последняя стадия.
 						// Решение: 2) Дать это на управление в queue, добавлять в buffer и queue  в отдельной горутине будет
 						for _, child := range parsable.GetChildren() {
+							// links of a near-duplicate lead mostly to the same pages as links of its cluster, assets are saved anyway
+							if _, isLink := child.(*internal.Page); isLink && config.NearDupSkipLinks && isNearDuplicate(item) {
+								continue
+							}
 							queue.Push(child)
 						}
 					}
@@ -251,7 +564,7 @@ func parseStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt
 	return outCh
 }
 
-func saveStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt int, bufferSize int, config *internal.Config, logger *slog.Logger) chan internal.Queueable {
+func saveStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt int, bufferSize int, output *safefs.Root, blobs *internal.BlobStore, fetchIndex *internal.FetchIndex, mirrorSync *internal.MirrorSync, report *internal.Report, config *internal.Config, logger *slog.Logger) chan internal.Queueable {
 	// disk ops too slow, maybe we need more workers?
 	outCh := make(chan internal.Queueable, bufferSize)
 
@@ -274,19 +587,62 @@ func saveStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt i
 					logId := item.(internal.Queueable).ItemId()
 					logger.Debug(fmt.Sprintf("Item '%s' received by the 'save' stage", logId))
 
-					path, err := retry.Retry[string](ctx, func() (string, error) {
-						p, saveErr := saveItem(ctx, config.OutputDir, item.(internal.Savable))
-						if saveErr != nil {
-							return "", saveErr
+					// Transform replaces the content, the original is kept to parse it when the item is not modified
+					original := item.(internal.Savable).GetContent()
+					if _, ok := item.(internal.Parsable); !ok {
+						original = nil
+					}
+
+					var paths []string
+					var err error
+					if isNotModified(item) {
+						logger.Debug(fmt.Sprintf("Item '%s' is not modified, the saved copy is kept.", logId))
+						original = nil
+					} else if item.GetSkippedOn() == "download" {
+						// an empty file would replace the copy saved by a previous crawl
+						logger.Debug(fmt.Sprintf("Item '%s' is not downloaded, the saved copy is kept.", logId))
+						original = nil
+					} else {
+						paths, err = retry.Retry[[]string](ctx, func() ([]string, error) {
+							return saveItem(ctx, output, blobs, item.(internal.Savable), config)
+						}, retry.NewConfig(
+							retry.WithMaxAttempts(config.RetryAttempts),
+							retry.WithDelay(config.RetryDelay),
+							retry.WithRetryableChecker(isRetryableSaveErr),
+						))
+					}
+
+					// an alias is downloaded in full next time: the saved stub is useless if it becomes the primary page,
+					// a skipped item has no response to revalidate
+					if page, ok := item.(*internal.Page); ok && page.DuplicateOf != nil && fetchIndex != nil {
+						fetchIndex.Forget(logId)
+					} else if err == nil && item.GetSkippedOn() == "" && fetchIndex != nil {
+						savedPath := ""
+						if len(paths) > 0 {
+							savedPath = paths[0]
+						}
+						if commitErr := fetchIndex.Commit(logId, savedPath, original); commitErr != nil {
+							logger.Warn(fmt.Sprintf("Item '%s' will be downloaded again next time: %v.", logId, commitErr))
 						}
-						return p, nil
-					}, retry.NewConfig(retry.WithMaxAttempts(config.RetryAttempts), retry.WithDelay(config.RetryDelay)))
+					}
 
-					if err != nil {
+					var pathErr *safefs.PathError
+					if errors.As(err, &pathErr) {
+						logger.Warn(fmt.Sprintf("Item '%s' saving rejected: %v.", logId, err))
+						report.RecordUnsafePath(logId, pathErr)
+						item.SetSkipped("save")
+					} else if err != nil {
 						logger.Debug(fmt.Sprintf("Item '%s' saving skipped, after %d attempts, with error: %v.", logId, config.RetryAttempts, err))
 						item.SetSkipped("save")
+					} else if len(paths) > 0 {
+						logger.Debug(fmt.Sprintf("Item '%s' saved to '%s'.", logId, filepath.Join(output.Dir(), paths[0])))
+					}
+
+					// the files of a reached item are not stale, even if they are not rewritten
+					if err == nil && len(paths) > 0 {
+						mirrorSync.Produced(logId, paths)
 					} else {
-						logger.Debug(fmt.Sprintf("Item '%s' saved to '%s'.", logId, path))
+						mirrorSync.Kept(logId)
 					}
 
 					select {
@@ -308,57 +664,237 @@ func saveStage(ctx context.Context, inCh <-chan internal.Queueable, workersCnt i
 	return outCh
 }
 
-func downloadItem(ctx context.Context, item internal.Downloadable, config *internal.Config, httpClientPool *sync.Pool) error {
-	client := httpClientPool.Get().(*httpclient.Client)
-	defer httpClientPool.Put(client)
+// isRetryableSaveErr - небезопасный путь останется таким же и при повторе.
+func isRetryableSaveErr(err error) bool {
+	var pathErr *safefs.PathError
+	return !errors.As(err, &pathErr)
+}
+
+// isRetryableDownloadErr - нет смысла повторять загрузки, отклоненные правилами фильтрации или DialGuard.
+func isRetryableDownloadErr(err error) bool {
+	var rejection *internal.FilterRejection
+	var blocked *httpclient.BlockedAddressError
+	var certErr *tls.CertificateVerificationError
+	return !errors.As(err, &rejection) && !errors.As(err, &blocked) && !errors.As(err, &certErr)
+}
+
+func isNearDuplicate(item internal.Queueable) bool {
+	page, ok := item.(*internal.Page)
+	return ok && page.NearDuplicateOf != ""
+}
+
+func isNotModified(item internal.Queueable) bool {
+	revalidatable, ok := item.(internal.Revalidatable)
+	return ok && revalidatable.IsNotModified()
+}
+
+func isLoginItem(login *internal.Login, item internal.Downloadable) bool {
+	u, err := url.Parse(item.GetURL())
+	return err == nil && login.IsLoginURL(u)
+}
+
+func downloadItem(ctx context.Context, item internal.Downloadable, config *internal.Config, httpClient *httpclient.Client, login *internal.Login, fetchIndex *internal.FetchIndex, metrics *internal.Metrics, report *internal.Report) error {
+	// filtering rules are checked as soon as something new is known: by the extension, by HEAD and by GET headers
+	if err := config.Filters.Check(internal.NewFilterCandidate(item.GetURL(), "", -1), "extension"); err != nil {
+		return err
+	}
+
+	// the item was saved by a previous crawl: only changes are requested, HEAD is not needed
+	var record internal.FetchRecord
+	var revalidate bool
+	if fetchIndex != nil {
+		record, revalidate = fetchIndex.Revalidate(item.GetURL())
+	}
+
+	if !revalidate {
+		if err := checkHead(ctx, item, config, httpClient); err != nil {
+			return err
+		}
+	}
+
+	get := func() (*httpclient.Response, error) {
+		if revalidate {
+			return httpClient.GetIfModified(ctx, item.GetURL(), record.Validators())
+		}
+		return httpClient.Get(ctx, item.GetURL())
+	}
+
+	requestedAt := time.Now()
+	resp, err := get()
+	if err != nil {
+		return err
+	}
+	metrics.RecordTransfer(resp.EncodedSize(), resp.DecodedSize())
 
+	// the session has expired and the server redirected to the login page: log in again and repeat the request
+	if login != nil && login.IsLoginURL(resp.URL) && !isLoginItem(login, item) {
+		if err := login.Refresh(ctx, requestedAt); err != nil {
+			return err
+		}
+
+		resp, err = get()
+		if err != nil {
+			return err
+		}
+		metrics.RecordTransfer(resp.EncodedSize(), resp.DecodedSize())
+
+		if login.IsLoginURL(resp.URL) {
+			return fmt.Errorf("%w: still redirected to the login page", internal.ErrLoginFailed)
+		}
+	}
+
+	if resp.TLS != nil {
+		report.RecordTLS(item.GetURL(), resp.TLS)
+	}
+
+	if resp.NotModified() {
+		metrics.RecordNotModified()
+		return setNotModified(item, record, resp, fetchIndex)
+	}
+
+	if err := config.Filters.Check(internal.NewFilterCandidate(item.GetURL(), resp.ContentType(), int64(len(resp.Content))), "get"); err != nil {
+		return err
+	}
+
+	err = item.SetContent(resp.Content)
+	if err != nil {
+		return err
+	}
+	item.SetContentType(resp.ContentType())
+
+	hash, fetchedAt := internal.ContentHash(resp.Content), time.Now()
+	if traceable, ok := item.(internal.Traceable); ok {
+		traceable.GetMeta().SetResponse(item.GetURL(), resp, hash, fetchedAt)
+	}
+
+	if fetchIndex != nil {
+		fetchIndex.Fetched(item.GetURL(), internal.FetchRecord{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			ContentType:  resp.ContentType(),
+			Hash:         hash,
+			FetchedAt:    fetchedAt,
+		})
+	}
+
+	return nil
+}
+
+// checkHead проверяет размер и фильтры до загрузки тела.
+func checkHead(ctx context.Context, item internal.Downloadable, config *internal.Config, httpClient *httpclient.Client) error {
 	// 1) Try a HEAD request before GET. However, some servers return Content-Length: 0
 	//    or the URL provides a stream-like response.
 	// 2) If HEAD is not supported or doesn't provide a valid size, read the GET response
 	// 	  and stop when the size limit is exceeded.
-	head, err := client.Head(ctx, item.GetURL())
+
+	head, err := httpClient.Head(ctx, item.GetURL())
 	if err != nil {
 		return err
 	}
 
+	headSize := int64(-1)
 	contentLenHeader := head.Header.Get("Content-Length")
 	if contentLenHeader != "" {
 		size, err := strconv.ParseInt(head.Header.Get("Content-Length"), 10, 64)
 		if err == nil && size > config.MaxFileSize {
-			return fmt.Errorf("content size exceeds limit: %d", config.MaxFileSize)
+			return &httpclient.LimitError{Kind: httpclient.LimitBodySize, Limit: config.MaxFileSize}
+		}
+		if err == nil && size > 0 {
+			headSize = size
 		}
 	}
 
-	content, err := client.Get(ctx, item.GetURL())
-	if err != nil {
+	if err := config.Filters.Check(internal.NewFilterCandidate(item.GetURL(), head.Header.Get("Content-Type"), headSize), "head"); err != nil {
 		return err
 	}
 
-	err = item.SetContent(content)
+	return nil
+}
+
+// setNotModified - содержимое не скачивается: страница получает сохраненный оригинал, чтобы ссылки в ней
+// продолжали обходиться, остальные элементы - ничего, их сохраненная копия не меняется.
+func setNotModified(item internal.Downloadable, record internal.FetchRecord, resp *httpclient.Response, fetchIndex *internal.FetchIndex) error {
+	content, err := fetchIndex.LoadOriginal(record)
 	if err != nil {
 		return err
 	}
 
+	// 304 may carry updated validators
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		record.ETag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		record.LastModified = lastModified
+	}
+	record.FetchedAt = time.Now()
+	fetchIndex.Fetched(item.GetURL(), record)
+
+	if err := item.SetContent(content); err != nil {
+		return err
+	}
+	item.SetContentType(record.ContentType)
+
+	if revalidatable, ok := item.(internal.Revalidatable); ok {
+		revalidatable.SetNotModified()
+	}
 	return nil
 }
 
-func saveItem(ctx context.Context, baseDir string, item internal.Savable) (string, error) {
-	savePath := filepath.Join(baseDir, item.ResolveRelativeSavePath())
+// saveItem пишет файлы только через output: пути получены из удаленных url и могут быть враждебными.
+// Возвращает записанные пути относительно output, первый - сам элемент.
+func saveItem(ctx context.Context, output *safefs.Root, blobs *internal.BlobStore, item internal.Savable, config *internal.Config) ([]string, error) {
+	savePath := item.ResolveRelativeSavePath()
 
-	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
-		return "", fmt.Errorf("create directory: %w", err)
+	// check the path before transforming
+	if _, err := safefs.Clean(savePath); err != nil {
+		return nil, err
 	}
 
 	if transformable, ok := item.(internal.Transformable); ok {
 		err := transformable.Transform()
 		if err != nil {
-			return "", fmt.Errorf("transform file: %w", err)
+			return nil, fmt.Errorf("transform file: %w", err)
 		}
 	}
 
-	if err := os.WriteFile(savePath, item.GetContent(), 0644); err != nil {
-		return "", fmt.Errorf("write file: %w", err)
+	// pages are unique and rewritten after the crawl (see RecomputeIntegrity), only assets share content
+	_, isPage := item.(*internal.Page)
+	deduplicated := blobs != nil && !isPage
+	if deduplicated {
+		if err := blobs.Save(savePath, item.GetContent()); err != nil {
+			return nil, err
+		}
+	} else if err := output.WriteFile(savePath, item.GetContent(), 0644); err != nil {
+		return nil, fmt.Errorf("write file: %w", err)
+	}
+	paths := []string{savePath}
+
+	if aliasable, ok := item.(internal.Aliasable); ok {
+		for _, aliasPath := range aliasable.GetAliasPaths() {
+			stub := internal.RenderRedirectStub(aliasPath, savePath)
+			if err := output.WriteFile(aliasPath, stub, 0644); err != nil {
+				return nil, fmt.Errorf("write alias: %w", err)
+			}
+			paths = append(paths, aliasPath)
+		}
+	}
+
+	// skipped items have no response to describe
+	if traceable, ok := item.(internal.Traceable); ok && traceable.GetMeta().IsFetched() {
+		meta := traceable.GetMeta()
+		if config.MetaSidecars {
+			if err := meta.WriteSidecar(output, savePath); err != nil {
+				return nil, err
+			}
+			paths = append(paths, savePath+internal.MetaSuffix)
+		}
+		// a blob is shared by all its links, so it has no mtime of its own
+		if config.MtimeFromLastModified && !deduplicated {
+			if err := meta.ApplyLastModified(output, savePath); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	return savePath, nil
+	return paths, nil
 }
@@ -2,6 +2,8 @@ module github.com/gallyamow/go-crawler
 
 go 1.24.0
 
-require golang.org/x/net v0.46.0
-
-require golang.org/x/sync v0.18.0 // indirect
+require (
+	github.com/andybalholm/brotli v1.2.0
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/net v0.46.0
+)
@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+)
+
+// Aliasable - элемент, на который в локальной копии ссылаются еще и по другим путям.
+// По этим путям сохраняются html-заглушки с редиректом на основной файл.
+type Aliasable interface {
+	GetAliasPaths() []string
+}
+
+// RenderRedirectStub возвращает html-страницу для сохранения по stubPath, которая перенаправляет на targetPath.
+func RenderRedirectStub(stubPath, targetPath string) []byte {
+	escaped := html.EscapeString(makeRelativeURL(stubPath, targetPath))
+
+	return fmt.Appendf(nil, `<!DOCTYPE html>
+<html><head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0;url=%s">
+<title>Redirect</title>
+</head><body><a href="%s">%s</a></body></html>
+`, escaped, escaped, escaped)
+}
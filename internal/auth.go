@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"os"
+	"strings"
+)
+
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthHeader = "header"
+)
+
+// AuthRule - учетные данные для хоста из config-файла. Сами секреты в файле не хранятся, только ссылки на них (SecretRef).
+type AuthRule struct {
+	// Host - "wiki.example.com", "wiki.example.com:8443" или "*.example.com", см. httpclient.HostAuth.
+	Host     string    `json:"host"`
+	Type     string    `json:"type"`
+	Username string    `json:"username"`
+	Password SecretRef `json:"password"`
+	Token    SecretRef `json:"token"`
+	Header   string    `json:"header"`
+	Value    SecretRef `json:"value"`
+	// AllowHTTP - отправлять учетные данные и по http, по умолчанию только по https.
+	AllowHTTP bool `json:"allow_http"`
+}
+
+// SecretRef - откуда взять секрет: из переменной окружения или из файла (например, docker/k8s secret).
+type SecretRef struct {
+	Env  string `json:"env"`
+	File string `json:"file"`
+}
+
+func (r SecretRef) resolve() (httpclient.Secret, error) {
+	switch {
+	case r.Env != "" && r.File != "":
+		return "", fmt.Errorf("secret must be set either by env or by file")
+	case r.Env != "":
+		value, ok := os.LookupEnv(r.Env)
+		if !ok || value == "" {
+			return "", fmt.Errorf("env %s is empty", r.Env)
+		}
+		return httpclient.Secret(value), nil
+	case r.File != "":
+		data, err := os.ReadFile(r.File)
+		if err != nil {
+			return "", fmt.Errorf("read secret file: %w", err)
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("secret file %q is empty", r.File)
+		}
+		return httpclient.Secret(value), nil
+	default:
+		return "", fmt.Errorf("secret is not set, use env or file")
+	}
+}
+
+func (r AuthRule) resolve() (httpclient.HostAuth, error) {
+	if r.Host == "" {
+		return httpclient.HostAuth{}, fmt.Errorf("auth rule: host cannot be empty")
+	}
+
+	wrap := func(field string, err error) error {
+		return fmt.Errorf("auth rule %s: %s: %w", r.Host, field, err)
+	}
+
+	var auth httpclient.Authenticator
+	switch r.Type {
+	case AuthBasic:
+		password, err := r.Password.resolve()
+		if err != nil {
+			return httpclient.HostAuth{}, wrap("password", err)
+		}
+		auth = httpclient.BasicAuth{Username: r.Username, Password: password}
+	case AuthBearer:
+		token, err := r.Token.resolve()
+		if err != nil {
+			return httpclient.HostAuth{}, wrap("token", err)
+		}
+		auth = httpclient.BearerAuth{Token: token}
+	case AuthHeader:
+		if r.Header == "" {
+			return httpclient.HostAuth{}, fmt.Errorf("auth rule %s: header cannot be empty", r.Host)
+		}
+		value, err := r.Value.resolve()
+		if err != nil {
+			return httpclient.HostAuth{}, wrap("value", err)
+		}
+		auth = httpclient.HeaderAuth{Name: r.Header, Value: value}
+	default:
+		return httpclient.HostAuth{}, fmt.Errorf("auth rule %s: type must be one of basic, bearer, header, got %q", r.Host, r.Type)
+	}
+
+	return httpclient.HostAuth{Host: r.Host, Auth: auth, AllowHTTP: r.AllowHTTP}, nil
+}
+
+// resolveAuth читает секреты всех правил при загрузке конфигурации, чтобы ошибки в них были видны до начала обхода.
+func resolveAuth(rules []AuthRule) ([]httpclient.HostAuth, error) {
+	var res []httpclient.HostAuth
+	for _, rule := range rules {
+		auth, err := rule.resolve()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, auth)
+	}
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigLoadFileAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("t0ken\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_WIKI_PASSWORD", "pa55")
+	t.Setenv("TEST_API_KEY", "k3y")
+
+	configFile := filepath.Join(dir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"auth": [
+		{"host": "wiki.example.com", "type": "basic", "username": "crawler", "password": {"env": "TEST_WIKI_PASSWORD"}},
+		{"host": "api.example.com", "type": "bearer", "token": {"file": "`+tokenFile+`"}},
+		{"host": "*.docs.example.com", "type": "header", "header": "X-Api-Key", "value": {"env": "TEST_API_KEY"}}
+	]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{}
+	if err := config.loadFile(configFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(config.Auth) != 3 {
+		t.Fatalf("got %d auth rules, want 3", len(config.Auth))
+	}
+
+	wantHeaders := []struct{ name, value string }{
+		{"Authorization", "Basic Y3Jhd2xlcjpwYTU1"},
+		{"Authorization", "Bearer t0ken"},
+		{"X-Api-Key", "k3y"},
+	}
+	for i, want := range wantHeaders {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		config.Auth[i].Auth.Authenticate(req)
+		if got := req.Header.Get(want.name); got != want.value {
+			t.Errorf("rule %d: %s = %q, want %q", i, want.name, got, want.value)
+		}
+	}
+
+	if s := config.String(); strings.Contains(s, "pa55") || strings.Contains(s, "t0ken") || strings.Contains(s, "k3y") {
+		t.Errorf("secret in Config.String(): %s", s)
+	}
+}
+
+func TestConfigLoadFileAuthErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"missing_env", `{"host": "a.com", "type": "bearer", "token": {"env": "TEST_MISSING_TOKEN"}}`},
+		{"missing_file", `{"host": "a.com", "type": "bearer", "token": {"file": "/nonexistent/token"}}`},
+		{"no_secret", `{"host": "a.com", "type": "basic", "username": "u"}`},
+		{"unknown_type", `{"host": "a.com", "type": "digest"}`},
+		{"no_host", `{"type": "bearer", "token": {"env": "HOME"}}`},
+		{"no_header", `{"host": "a.com", "type": "header", "value": {"env": "HOME"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(configFile, []byte(`{"auth": [`+tt.rule+`]}`), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := (&Config{}).loadFile(configFile); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"io/fs"
+	pathlib "path"
+	"sync"
+)
+
+const blobsDir = "blobs"
+
+// DedupMode определяет, как пути копии ссылаются на общее содержимое (см. BlobStore).
+type DedupMode string
+
+const (
+	// DedupModeOff - каждый файл пишется отдельно.
+	DedupModeOff DedupMode = "off"
+	// DedupModeHardlink - жесткие ссылки: копия выглядит как обычные файлы, но все ссылки делят mtime.
+	DedupModeHardlink DedupMode = "hardlink"
+	// DedupModeSymlink - относительные symlinks, переживают копирование на другую файловую систему.
+	DedupModeSymlink DedupMode = "symlink"
+)
+
+func (m DedupMode) Valid() bool {
+	switch m {
+	case DedupModeOff, DedupModeHardlink, DedupModeSymlink:
+		return true
+	}
+	return false
+}
+
+// BlobStore хранит содержимое один раз в <state-dir>/blobs/<hh>/<sha256>, а пути копии становятся ссылками на blob.
+// Blobs не удаляются, даже если на них больше никто не ссылается.
+type BlobStore struct {
+	output *safefs.Root
+	mode   DedupMode
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+	// sizes - blobs, на которые сослались в этом обходе
+	sizes map[string]int
+	files int
+	bytes int64
+}
+
+// DedupStats - сколько файлов и байт сохранено через BlobStore и сколько места они занимают.
+type DedupStats struct {
+	Files       int
+	Blobs       int
+	Bytes       int64
+	StoredBytes int64
+}
+
+// Ratio - во сколько раз копия меньше, чем без dedup, 0 - ничего не сохранялось.
+func (s DedupStats) Ratio() float64 {
+	if s.StoredBytes == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.StoredBytes)
+}
+
+func NewBlobStore(output *safefs.Root, mode DedupMode) *BlobStore {
+	// symlinks to blobs are read, e.g. to revalidate a linked file (see FetchIndex)
+	_ = output.FollowLinksInto(pathlib.Join(StateDirName, blobsDir))
+
+	return &BlobStore{
+		output: output,
+		mode:   mode,
+		locks:  map[string]*sync.Mutex{},
+		sizes:  map[string]int{},
+	}
+}
+
+// Save сохраняет content в blob, если его еще нет, и заменяет path ссылкой на него.
+// Blob, оставшийся от прошлого обхода, сверяется с content (один раз за обход): через жесткую ссылку его могли
+// изменить вместе с файлом копии, а запись могла прерваться.
+func (s *BlobStore) Save(path string, content []byte) error {
+	hash := ContentHash(content)
+	blobPath := pathlib.Join(StateDirName, blobsDir, hash[:2], hash)
+
+	// the same content can be saved concurrently for different urls
+	lock := s.lock(hash)
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.mu.Lock()
+	_, verified := s.sizes[hash]
+	s.mu.Unlock()
+
+	if !verified {
+		stored, err := s.output.ReadFile(blobPath)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && ContentHash(stored) != hash) {
+			err = s.output.WriteFile(blobPath, content, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("write blob: %w", err)
+		}
+	}
+
+	if err := s.output.Link(blobPath, path, s.mode == DedupModeSymlink); err != nil {
+		return fmt.Errorf("link blob: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files++
+	s.bytes += int64(len(content))
+	s.sizes[hash] = len(content)
+
+	return nil
+}
+
+func (s *BlobStore) Stats() DedupStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := DedupStats{Files: s.files, Blobs: len(s.sizes), Bytes: s.bytes}
+	for _, size := range s.sizes {
+		stats.StoredBytes += int64(size)
+	}
+	return stats
+}
+
+func (s *BlobStore) lock(hash string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[hash]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[hash] = lock
+	}
+	return lock
+}
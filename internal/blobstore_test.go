@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlobStore(t *testing.T) {
+	for _, mode := range []DedupMode{DedupModeHardlink, DedupModeSymlink} {
+		t.Run(string(mode), func(t *testing.T) {
+			output, err := safefs.Open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer output.Close()
+
+			store := NewBlobStore(output, mode)
+			files := map[string]string{
+				"/js/jquery.js":         "jquery",
+				"/vendor/jquery.min.js": "jquery",
+				"/cdn/a/b/jquery.js":    "jquery",
+				"/logo.png":             "logo",
+			}
+			for path, content := range files {
+				if err := store.Save(path, []byte(content)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			for path, content := range files {
+				if got, err := output.ReadFile(path); err != nil || string(got) != content {
+					t.Errorf("%s: got %q, %v", path, got, err)
+				}
+			}
+
+			a, _ := os.Stat(filepath.Join(output.Dir(), "js", "jquery.js"))
+			b, _ := os.Stat(filepath.Join(output.Dir(), "cdn", "a", "b", "jquery.js"))
+			if !os.SameFile(a, b) {
+				t.Error("identical files do not share a blob")
+			}
+
+			want := DedupStats{Files: 4, Blobs: 2, Bytes: 22, StoredBytes: 10}
+			if got := store.Stats(); got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+			if got := store.Stats().Ratio(); got != 2.2 {
+				t.Errorf("got ratio %v, want 2.2", got)
+			}
+		})
+	}
+}
+
+func TestBlobStoreCorruptedBlob(t *testing.T) {
+	output, err := safefs.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	if err := NewBlobStore(output, DedupModeHardlink).Save("/app.js", []byte("app")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the blob is changed through its hardlink by the previous crawl
+	if err := os.WriteFile(filepath.Join(output.Dir(), "app.js"), []byte("ap"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewBlobStore(output, DedupModeHardlink).Save("/vendor/app.js", []byte("app")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := output.ReadFile("/vendor/app.js"); err != nil || string(got) != "app" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
@@ -1,11 +1,18 @@
 package internal
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/dnscache"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
 	"log/slog"
+	"net/netip"
+	urllib "net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,40 +21,236 @@ type Config struct {
 	MaxConcurrent int
 	MaxFileSize   int64
 	URL           string
-	Timeout       time.Duration
 	RetryAttempts int
 	RetryDelay    time.Duration
 	OutputDir     string
+	ReportFile    string
+	SRIPolicy     SRIPolicy
 	LogLevel      string
+	ConfigFile    string
+
+	// connections, see httpclient.TransportConfig
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	BodyTimeout           time.Duration
+	// Timeout - весь запрос вместе с чтением тела, 0 - только таймауты этапов выше.
+	// Раньше это был единственный таймаут (30s), теперь его заменяют таймауты этапов.
+	Timeout             time.Duration
+	KeepAlive           time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	DisableHTTP2        bool
+	// RateLimit - запросов в секунду к одному хосту, 0 без ограничения.
+	RateLimit float64
+	// CookieJar - файл Netscape cookies.txt, пустой - cookies не сохраняются.
+	CookieJar string
+	// Incremental - условные запросы для элементов, сохраненных прошлым обходом (см. FetchIndex).
+	Incremental bool
+	// CacheDir - HTTP-кэш ответов (см. httpclient.HTTPCacheMiddleware), пустой - без кэша.
+	// CacheOnly - только ответы из кэша, без обращения к сети.
+	CacheDir  string
+	CacheOnly bool
+	// MetaSidecars - <file>.meta.json с происхождением каждого сохраненного файла (см. ItemMeta).
+	MetaSidecars bool
+	// MtimeFromLastModified - mtime сохраненного файла берется из Last-Modified.
+	MtimeFromLastModified bool
+	// Sync - что делать с файлами, до которых полный обход больше не доходит (см. MirrorSync).
+	Sync         SyncMode
+	SyncDryRun   bool
+	SyncMaxRatio float64
+	// Dedup - одинаковые assets хранятся один раз (см. BlobStore).
+	Dedup DedupMode
+	// PageDedup - страницы с одинаковым телом или canonical url сохраняются один раз (см. PageDedup).
+	PageDedup PageDedupMode
+	// NearDupDistance - страницы, отпечатки текста которых различаются не больше чем в стольких битах,
+	// считаются почти одинаковыми (см. NearDedup), 0 - не искать. NearDupSkipLinks - не обходить их ссылки.
+	NearDupDistance  int
+	NearDupSkipLinks bool
+
+	// response limits, see httpclient.Limits
+	MaxDecompressionRatio float64
+	MinTransferRate       int64
+	MaxHeaderSize         int64
+
+	// Proxy - прокси по умолчанию, NoProxy - исключения (см. httpclient.ProxyRouter). Без них - прокси из окружения.
+	Proxy      string
+	NoProxy    string
+	ProxyRules []httpclient.ProxyRule
+
+	// DNSCacheTTL - 0 отключает кэш DNS. Resolve - адреса для "host:port", как curl --resolve.
+	DNSCacheTTL time.Duration
+	Resolve     map[string][]netip.Addr
+
+	// TLS, see httpclient.TLSConfig
+	CACerts       []string
+	TLSMinVersion string
+
+	// SSRF protection, see httpclient.DialGuard
+	AllowPrivateNetworks bool
+	DenyCIDRs            []netip.Prefix
+	AllowCIDRs           []netip.Prefix
+
+	// sections of the config file
+	Filters Filters
+	Headers map[string]string
+	// Auth - учетные данные с прочитанными секретами, в String() попадают только хосты.
+	Auth []httpclient.HostAuth
+	// Login - nil без входа.
+	Login *LoginConfig
+	// TLSHosts - настройки TLS хостов с загруженными клиентскими сертификатами.
+	TLSHosts []httpclient.TLSHost
+}
+
+// fileConfig - секции, которые удобнее задавать json-файлом, чем флагами.
+type fileConfig struct {
+	Filters Filters `json:"filters"`
+	// Headers - дополнительные заголовки всех запросов.
+	Headers map[string]string `json:"headers"`
+	Auth    []AuthRule        `json:"auth"`
+	Login   *LoginConfig      `json:"login"`
+	// ProxyRules - прокси для отдельных хостов, "direct" - без прокси.
+	ProxyRules []struct {
+		Hosts []string `json:"hosts"`
+		Proxy string   `json:"proxy"`
+	} `json:"proxy_rules"`
+	TLS []TLSRule `json:"tls"`
 }
 
 // LoadConfig loads configuration from environment variables and command line flags.
 func LoadConfig() (*Config, error) {
 	config := &Config{}
+	var err error
 
 	// Set defaults
 	config.MaxCount = getEnvInt("CRAWLER_MAX_COUNT", 100)
 	config.MaxConcurrent = getEnvInt("CRAWLER_MAX_CONCURRENT", 10)
 	config.URL = getEnvString("CRAWLER_URL", "")
-	config.Timeout = getEnvDuration("CRAWLER_TIMEOUT", 30*time.Second)
+	config.DialTimeout = getEnvDuration("CRAWLER_DIAL_TIMEOUT", 10*time.Second)
+	config.TLSHandshakeTimeout = getEnvDuration("CRAWLER_TLS_HANDSHAKE_TIMEOUT", 10*time.Second)
+	config.ResponseHeaderTimeout = getEnvDuration("CRAWLER_RESPONSE_HEADER_TIMEOUT", 30*time.Second)
+	config.BodyTimeout = getEnvDuration("CRAWLER_BODY_TIMEOUT", 60*time.Second)
+	config.Timeout = getEnvDuration("CRAWLER_TIMEOUT", 0)
+	config.KeepAlive = getEnvDuration("CRAWLER_KEEP_ALIVE", 30*time.Second)
+	config.IdleConnTimeout = getEnvDuration("CRAWLER_IDLE_CONN_TIMEOUT", 90*time.Second)
+	config.MaxIdleConnsPerHost = getEnvInt("CRAWLER_MAX_IDLE_CONNS_PER_HOST", 10)
+	config.MaxConnsPerHost = getEnvInt("CRAWLER_MAX_CONNS_PER_HOST", 0)
+	config.DisableHTTP2 = getEnvBool("CRAWLER_DISABLE_HTTP2", false)
+	config.RateLimit = getEnvFloat("CRAWLER_RATE_LIMIT", 0)
+	config.CookieJar = getEnvString("CRAWLER_COOKIE_JAR", "")
+	config.Incremental = getEnvBool("CRAWLER_INCREMENTAL", false)
+	config.CacheDir = getEnvString("CRAWLER_CACHE_DIR", "")
+	config.CacheOnly = getEnvBool("CRAWLER_CACHE_ONLY", false)
+	config.MetaSidecars = getEnvBool("CRAWLER_META_SIDECARS", false)
+	config.MtimeFromLastModified = getEnvBool("CRAWLER_MTIME_FROM_LAST_MODIFIED", false)
+	syncMode := getEnvString("CRAWLER_SYNC", string(SyncModeOff))
+	config.SyncDryRun = getEnvBool("CRAWLER_SYNC_DRY_RUN", false)
+	config.SyncMaxRatio = getEnvFloat("CRAWLER_SYNC_MAX_RATIO", 0.2)
+	dedupMode := getEnvString("CRAWLER_DEDUP", string(DedupModeOff))
+	pageDedupMode := getEnvString("CRAWLER_PAGE_DEDUP", string(PageDedupExact))
+	config.NearDupDistance = getEnvInt("CRAWLER_NEAR_DUP_DISTANCE", 3)
+	config.NearDupSkipLinks = getEnvBool("CRAWLER_NEAR_DUP_SKIP_LINKS", false)
 	config.RetryAttempts = getEnvInt("CRAWLER_RETRY_ATTEMPTS", 3)
 	config.RetryDelay = getEnvDuration("CRAWLER_RETRY_DELAY", 1*time.Second)
 	config.OutputDir = getEnvString("CRAWLER_OUTPUT_DIR", "./.tmp/")
+	config.ReportFile = getEnvString("CRAWLER_REPORT_FILE", "")
+	sriPolicy := getEnvString("CRAWLER_SRI_POLICY", string(SRIPolicyStrip))
 	config.LogLevel = getEnvString("CRAWLER_LOG_LEVEL", "info")
+	config.ConfigFile = getEnvString("CRAWLER_CONFIG", "")
 	config.MaxFileSize = getEnvInt64("CRAWLER_MAX_FILE_SIZE", 64<<20) // 64*2^20=64*1024*1024=64MB
+	config.MaxDecompressionRatio = getEnvFloat("CRAWLER_MAX_DECOMPRESSION_RATIO", 100)
+	config.MinTransferRate = getEnvInt64("CRAWLER_MIN_TRANSFER_RATE", 1<<10)
+	config.MaxHeaderSize = getEnvInt64("CRAWLER_MAX_HEADER_SIZE", 1<<20)
+	config.Proxy = getEnvString("CRAWLER_PROXY", "")
+	config.NoProxy = getEnvString("CRAWLER_NO_PROXY", "")
+	config.DNSCacheTTL = getEnvDuration("CRAWLER_DNS_CACHE_TTL", time.Minute)
+	resolve := stringList(strings.Fields(getEnvString("CRAWLER_RESOLVE", "")))
+	caCerts := getEnvString("CRAWLER_CA_CERTS", "")
+	config.TLSMinVersion = getEnvString("CRAWLER_TLS_MIN_VERSION", "1.2")
+	config.AllowPrivateNetworks = getEnvBool("CRAWLER_ALLOW_PRIVATE_NETWORKS", false)
+	denyCIDRs := getEnvString("CRAWLER_DENY_CIDRS", "")
+	allowCIDRs := getEnvString("CRAWLER_ALLOW_CIDRS", "")
 
 	// Parse command line flags
 	flag.IntVar(&config.MaxCount, "max-count", config.MaxCount, "Maximum number of pages to crawl")
 	flag.IntVar(&config.MaxConcurrent, "max-concurrent", config.MaxConcurrent, "Maximum number of concurrent workers")
 	flag.StringVar(&config.URL, "url", config.URL, "Starting sourceURL for crawling")
-	flag.DurationVar(&config.Timeout, "timeout", config.Timeout, "HTTP request timeout")
+	flag.DurationVar(&config.DialTimeout, "dial-timeout", config.DialTimeout, "Timeout for establishing a connection")
+	flag.DurationVar(&config.TLSHandshakeTimeout, "tls-handshake-timeout", config.TLSHandshakeTimeout, "Timeout for the TLS handshake")
+	flag.DurationVar(&config.ResponseHeaderTimeout, "response-header-timeout", config.ResponseHeaderTimeout, "Timeout for response headers after the request is sent")
+	flag.DurationVar(&config.BodyTimeout, "body-timeout", config.BodyTimeout, "Timeout for reading a response body")
+	flag.DurationVar(&config.Timeout, "timeout", config.Timeout, "Deadline for a whole request including the body, 0 for none (prefer the per-stage timeouts)")
+	flag.DurationVar(&config.KeepAlive, "keep-alive", config.KeepAlive, "TCP keep-alive period")
+	flag.DurationVar(&config.IdleConnTimeout, "idle-conn-timeout", config.IdleConnTimeout, "How long an idle connection is kept for reuse")
+	flag.IntVar(&config.MaxIdleConnsPerHost, "max-idle-conns-per-host", config.MaxIdleConnsPerHost, "Maximum idle connections kept per host")
+	flag.IntVar(&config.MaxConnsPerHost, "max-conns-per-host", config.MaxConnsPerHost, "Maximum connections per host, 0 for no limit")
+	flag.BoolVar(&config.DisableHTTP2, "disable-http2", config.DisableHTTP2, "Use HTTP/1.1 only")
+	flag.Float64Var(&config.RateLimit, "rate-limit", config.RateLimit, "Maximum requests per second to a single host, 0 for no limit")
+	flag.StringVar(&config.CookieJar, "cookie-jar", config.CookieJar, "Netscape cookies.txt file to load cookies from and save them to, empty to disable cookies")
+	flag.BoolVar(&config.Incremental, "incremental", config.Incremental, "Re-crawl an existing output dir with conditional requests, only changed items are downloaded and saved")
+	flag.StringVar(&config.CacheDir, "cache-dir", config.CacheDir, "Directory for the HTTP cache of responses (Cache-Control, Expires, Vary), empty to disable")
+	flag.BoolVar(&config.CacheOnly, "cache-only", config.CacheOnly, "Replay responses from the cache without network requests, missing ones are skipped")
+	flag.BoolVar(&config.MetaSidecars, "meta-sidecars", config.MetaSidecars, "Write <file>.meta.json next to every saved file: final URL, redirects, status, headers, fetch time, hash, referrer, depth")
+	flag.BoolVar(&config.MtimeFromLastModified, "mtime-from-last-modified", config.MtimeFromLastModified, "Set the modification time of saved files from the Last-Modified header")
+	flag.StringVar(&syncMode, "sync", syncMode, "What to do with files a complete crawl no longer reached (off, prune, quarantine)")
+	flag.BoolVar(&config.SyncDryRun, "sync-dry-run", config.SyncDryRun, "Only list the files sync would remove")
+	flag.Float64Var(&config.SyncMaxRatio, "sync-max-ratio", config.SyncMaxRatio, "Maximum share of tracked files sync may remove in one crawl, otherwise nothing is removed")
+	flag.StringVar(&dedupMode, "dedup", dedupMode, "Store identical assets once and link them from the mirror (off, hardlink, symlink)")
+	flag.StringVar(&pageDedupMode, "page-dedup", pageDedupMode, "Save pages with the same body or canonical URL once, the others become redirect stubs (off, exact, canonical)")
+	flag.IntVar(&config.NearDupDistance, "near-dup-distance", config.NearDupDistance, "Maximum Hamming distance between SimHash fingerprints of the visible text of near-duplicate pages, 0 to disable")
+	flag.BoolVar(&config.NearDupSkipLinks, "near-dup-skip-links", config.NearDupSkipLinks, "Do not follow links of near-duplicate pages")
 	flag.IntVar(&config.RetryAttempts, "retry-attempts", config.RetryAttempts, "Number of retry attempts for failed requests")
 	flag.DurationVar(&config.RetryDelay, "retry-delay", config.RetryDelay, "Delay between retry attempts")
 	flag.StringVar(&config.OutputDir, "output-dir", config.OutputDir, "Directory to save crawled pages")
+	flag.StringVar(&config.ReportFile, "report-file", config.ReportFile, "File to write the crawl report to (json), empty to disable")
+	flag.StringVar(&sriPolicy, "sri-policy", sriPolicy, "What to do with integrity attributes of saved assets (strip, recompute, keep)")
 	flag.StringVar(&config.LogLevel, "log-level", config.LogLevel, "Log level (debug, info, warn, error)")
+	flag.StringVar(&config.ConfigFile, "config", config.ConfigFile, "JSON file with extra sections (filters, headers, auth, login, proxy_rules, tls)")
+	flag.Int64Var(&config.MaxFileSize, "max-file-size", config.MaxFileSize, "Maximum size of a downloaded file after decompression (bytes)")
+	flag.Float64Var(&config.MaxDecompressionRatio, "max-decompression-ratio", config.MaxDecompressionRatio, "Maximum ratio of decompressed to received size, 0 to disable")
+	flag.Int64Var(&config.MinTransferRate, "min-transfer-rate", config.MinTransferRate, "Minimum response transfer rate (bytes/s), 0 to disable")
+	flag.Int64Var(&config.MaxHeaderSize, "max-header-size", config.MaxHeaderSize, "Maximum size of response headers (bytes)")
+	flag.StringVar(&config.Proxy, "proxy", config.Proxy, "Proxy for all requests: http://, https:// or socks5://[user:pass@]host:port")
+	flag.StringVar(&config.NoProxy, "no-proxy", config.NoProxy, "Comma-separated hosts, domains and networks to connect to directly (NO_PROXY syntax)")
+	flag.DurationVar(&config.DNSCacheTTL, "dns-cache-ttl", config.DNSCacheTTL, "How long resolved addresses are cached, 0 to disable the cache")
+	flag.Var(&resolve, "resolve", "Use addresses for host:port instead of DNS, host:port:addr[,addr] (can be repeated)")
+	flag.StringVar(&caCerts, "ca-certs", caCerts, "Comma-separated PEM files with CA certificates trusted in addition to the system ones")
+	flag.StringVar(&config.TLSMinVersion, "tls-min-version", config.TLSMinVersion, "Minimum TLS version (1.0, 1.1, 1.2, 1.3)")
+	flag.BoolVar(&config.AllowPrivateNetworks, "allow-private-networks", config.AllowPrivateNetworks, "Allow connections to loopback, private and link-local addresses (e.g. to crawl localhost)")
+	flag.StringVar(&denyCIDRs, "deny-cidrs", denyCIDRs, "Comma-separated networks to block in addition to the private ones")
+	flag.StringVar(&allowCIDRs, "allow-cidrs", allowCIDRs, "Comma-separated networks to allow even if they are blocked")
 
 	flag.Parse()
 
+	config.SRIPolicy = SRIPolicy(sriPolicy)
+	config.Sync = SyncMode(syncMode)
+	config.Dedup = DedupMode(dedupMode)
+	config.PageDedup = PageDedupMode(pageDedupMode)
+
+	if config.Resolve, err = parseResolve(resolve); err != nil {
+		return nil, fmt.Errorf("resolve: %w", err)
+	}
+
+	for _, path := range strings.Split(caCerts, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			config.CACerts = append(config.CACerts, path)
+		}
+	}
+
+	if config.DenyCIDRs, err = parseCIDRs(denyCIDRs); err != nil {
+		return nil, fmt.Errorf("deny-cidrs: %w", err)
+	}
+	if config.AllowCIDRs, err = parseCIDRs(allowCIDRs); err != nil {
+		return nil, fmt.Errorf("allow-cidrs: %w", err)
+	}
+
+	if config.ConfigFile != "" {
+		if err := config.loadFile(config.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
 	// Validate configuration
 	if err := config.validate(); err != nil {
 		return nil, err
@@ -56,6 +259,46 @@ func LoadConfig() (*Config, error) {
 	return config, nil
 }
 
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var fc fileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return fmt.Errorf("parse config file %q: %w", path, err)
+	}
+
+	c.Filters = fc.Filters
+	c.Headers = fc.Headers
+
+	auth, err := resolveAuth(fc.Auth)
+	if err != nil {
+		return fmt.Errorf("config file %q: %w", path, err)
+	}
+	c.Auth = auth
+
+	if fc.Login != nil {
+		if err := fc.Login.resolve(); err != nil {
+			return fmt.Errorf("config file %q: %w", path, err)
+		}
+	}
+	c.Login = fc.Login
+
+	tlsHosts, err := resolveTLS(fc.TLS)
+	if err != nil {
+		return fmt.Errorf("config file %q: %w", path, err)
+	}
+	c.TLSHosts = tlsHosts
+
+	for _, rule := range fc.ProxyRules {
+		c.ProxyRules = append(c.ProxyRules, httpclient.ProxyRule{Hosts: rule.Hosts, Proxy: rule.Proxy})
+	}
+
+	return nil
+}
+
 func (c *Config) validate() error {
 	if c.MaxCount <= 0 {
 		return fmt.Errorf("max-count must be positive, got %d", c.MaxCount)
@@ -66,8 +309,56 @@ func (c *Config) validate() error {
 	if c.URL == "" {
 		return fmt.Errorf("url cannot be empty")
 	}
-	if c.Timeout <= 0 {
-		return fmt.Errorf("timeout must be positive, got %v", c.Timeout)
+	if c.DialTimeout <= 0 {
+		return fmt.Errorf("dial-timeout must be positive, got %v", c.DialTimeout)
+	}
+	if c.TLSHandshakeTimeout <= 0 {
+		return fmt.Errorf("tls-handshake-timeout must be positive, got %v", c.TLSHandshakeTimeout)
+	}
+	if c.ResponseHeaderTimeout <= 0 {
+		return fmt.Errorf("response-header-timeout must be positive, got %v", c.ResponseHeaderTimeout)
+	}
+	if c.BodyTimeout <= 0 {
+		return fmt.Errorf("body-timeout must be positive, got %v", c.BodyTimeout)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative, got %v", c.Timeout)
+	}
+	if c.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("max-idle-conns-per-host cannot be negative, got %d", c.MaxIdleConnsPerHost)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("rate-limit cannot be negative, got %v", c.RateLimit)
+	}
+	if c.MaxConnsPerHost < 0 {
+		return fmt.Errorf("max-conns-per-host cannot be negative, got %d", c.MaxConnsPerHost)
+	}
+	if c.DNSCacheTTL < 0 {
+		return fmt.Errorf("dns-cache-ttl cannot be negative, got %v", c.DNSCacheTTL)
+	}
+	if c.CacheOnly && c.CacheDir == "" {
+		return fmt.Errorf("cache-only requires cache-dir")
+	}
+	if c.CacheOnly && c.Login != nil {
+		return fmt.Errorf("cache-only cannot be used with login")
+	}
+	if !c.Sync.Valid() {
+		return fmt.Errorf("sync must be one of off, prune, quarantine, got %q", c.Sync)
+	}
+	if c.SyncMaxRatio < 0 || c.SyncMaxRatio > 1 {
+		return fmt.Errorf("sync-max-ratio must be between 0 and 1, got %v", c.SyncMaxRatio)
+	}
+	if !c.Dedup.Valid() {
+		return fmt.Errorf("dedup must be one of off, hardlink, symlink, got %q", c.Dedup)
+	}
+	if !c.PageDedup.Valid() {
+		return fmt.Errorf("page-dedup must be one of off, exact, canonical, got %q", c.PageDedup)
+	}
+	if c.NearDupDistance < 0 || c.NearDupDistance > 63 {
+		return fmt.Errorf("near-dup-distance must be between 0 and 63, got %d", c.NearDupDistance)
+	}
+	if c.NearDupSkipLinks && c.NearDupDistance == 0 {
+		return fmt.Errorf("near-dup-skip-links requires near-dup-distance")
 	}
 	if c.RetryAttempts < 0 {
 		return fmt.Errorf("retry-attempts cannot be negative, got %d", c.RetryAttempts)
@@ -78,17 +369,118 @@ func (c *Config) validate() error {
 	if c.OutputDir == "" {
 		return fmt.Errorf("output-dir cannot be empty")
 	}
+	if c.MaxFileSize <= 0 {
+		return fmt.Errorf("max-file-size must be positive, got %d", c.MaxFileSize)
+	}
+	if c.MaxDecompressionRatio < 0 {
+		return fmt.Errorf("max-decompression-ratio cannot be negative, got %v", c.MaxDecompressionRatio)
+	}
+	if c.MinTransferRate < 0 {
+		return fmt.Errorf("min-transfer-rate cannot be negative, got %d", c.MinTransferRate)
+	}
+	if c.MaxHeaderSize <= 0 {
+		return fmt.Errorf("max-header-size must be positive, got %d", c.MaxHeaderSize)
+	}
+	if !c.SRIPolicy.Valid() {
+		return fmt.Errorf("sri-policy must be one of strip, recompute, keep, got %q", c.SRIPolicy)
+	}
+	if err := c.Filters.validate(); err != nil {
+		return err
+	}
+	if _, err := c.ProxyRouter(); err != nil {
+		return err
+	}
+	if _, err := c.TLSConfig(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func (c *Config) String() string {
 	return fmt.Sprintf(
-		"Config{MaxCount: %d, MaxConcurrent: %d, URL: %s, Timeout: %v, RetryAttempts: %d, RetryDelay: %v, OutputDir: %s, LogLevel: %s}",
-		c.MaxCount, c.MaxConcurrent, c.URL, c.Timeout, c.RetryAttempts, c.RetryDelay, c.OutputDir, c.LogLevel,
+		"Config{MaxCount: %d, MaxConcurrent: %d, MaxFileSize: %d, MaxDecompressionRatio: %v, MinTransferRate: %d, MaxHeaderSize: %d, URL: %s, DialTimeout: %v, TLSHandshakeTimeout: %v, ResponseHeaderTimeout: %v, BodyTimeout: %v, Timeout: %v, KeepAlive: %v, IdleConnTimeout: %v, MaxIdleConnsPerHost: %d, MaxConnsPerHost: %d, DisableHTTP2: %t, RateLimit: %v, CookieJar: %s, Incremental: %t, CacheDir: %s, CacheOnly: %t, MetaSidecars: %t, MtimeFromLastModified: %t, Sync: %s, SyncDryRun: %t, SyncMaxRatio: %v, Dedup: %s, PageDedup: %s, NearDupDistance: %d, NearDupSkipLinks: %t, RetryAttempts: %d, RetryDelay: %v, OutputDir: %s, ReportFile: %s, SRIPolicy: %s, LogLevel: %s, ConfigFile: %s, Proxy: %s, NoProxy: %s, ProxyRules: %d, DNSCacheTTL: %v, Resolve: %v, CACerts: %v, TLSMinVersion: %s, TLSHosts: %v, AllowPrivateNetworks: %t, DenyCIDRs: %v, AllowCIDRs: %v, Filters: %d, Headers: %d, Auth: %v, Login: %s}",
+		c.MaxCount, c.MaxConcurrent, c.MaxFileSize, c.MaxDecompressionRatio, c.MinTransferRate, c.MaxHeaderSize, c.URL, c.DialTimeout, c.TLSHandshakeTimeout, c.ResponseHeaderTimeout, c.BodyTimeout, c.Timeout, c.KeepAlive, c.IdleConnTimeout, c.MaxIdleConnsPerHost, c.MaxConnsPerHost, c.DisableHTTP2, c.RateLimit, c.CookieJar, c.Incremental, c.CacheDir, c.CacheOnly, c.MetaSidecars, c.MtimeFromLastModified, c.Sync, c.SyncDryRun, c.SyncMaxRatio, c.Dedup, c.PageDedup, c.NearDupDistance, c.NearDupSkipLinks, c.RetryAttempts, c.RetryDelay, c.OutputDir, c.ReportFile, c.SRIPolicy, c.LogLevel, c.ConfigFile, redactURL(c.Proxy), c.NoProxy, len(c.ProxyRules), c.DNSCacheTTL, c.Resolve, c.CACerts, c.TLSMinVersion, c.tlsHosts(), c.AllowPrivateNetworks, c.DenyCIDRs, c.AllowCIDRs, len(c.Filters), len(c.Headers), c.authHosts(), c.loginURL(),
 	)
 }
 
+// redactURL скрывает пароль в url (например, в адресе прокси).
+func redactURL(rawURL string) string {
+	if u, err := urllib.Parse(rawURL); err == nil {
+		return u.Redacted()
+	}
+	return rawURL
+}
+
+func (c *Config) authHosts() []string {
+	var hosts []string
+	for _, auth := range c.Auth {
+		hosts = append(hosts, auth.Host)
+	}
+	return hosts
+}
+
+func (c *Config) tlsHosts() []string {
+	var hosts []string
+	for _, host := range c.TLSHosts {
+		hosts = append(hosts, host.Host)
+	}
+	return hosts
+}
+
+func (c *Config) loginURL() string {
+	if c.Login == nil {
+		return ""
+	}
+	return c.Login.URL
+}
+
+// StateDir - служебная директория внутри OutputDir (индекс путей и прочие данные между запусками).
+func (c *Config) StateDir() string {
+	return filepath.Join(c.OutputDir, StateDirName)
+}
+
+// ProxyRouter - nil, если прокси не настроены (тогда используются переменные окружения HTTP_PROXY и т.д.).
+func (c *Config) ProxyRouter() (*httpclient.ProxyRouter, error) {
+	if c.Proxy == "" && c.NoProxy == "" && len(c.ProxyRules) == 0 {
+		return nil, nil
+	}
+	return httpclient.NewProxyRouter(c.Proxy, c.NoProxy, c.ProxyRules)
+}
+
+// TLSConfig читает файлы CACerts: при проверке конфигурации и при создании transport.
+func (c *Config) TLSConfig() (*httpclient.TLSConfig, error) {
+	minVersion, err := parseTLSVersion(c.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &httpclient.TLSConfig{MinVersion: minVersion, Hosts: c.TLSHosts}
+	if len(c.CACerts) > 0 {
+		if cfg.RootCAs, err = httpclient.LoadCertPool(c.CACerts...); err != nil {
+			return nil, fmt.Errorf("ca-certs: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// TransportConfig - настройки общего для всех запросов transport.
+func (c *Config) TransportConfig() httpclient.TransportConfig {
+	cfg := httpclient.DefaultTransportConfig()
+	cfg.DialTimeout = c.DialTimeout
+	cfg.KeepAlive = c.KeepAlive
+	cfg.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	cfg.ResponseHeaderTimeout = c.ResponseHeaderTimeout
+	cfg.IdleConnTimeout = c.IdleConnTimeout
+	cfg.MaxIdleConns = max(cfg.MaxIdleConns, c.MaxIdleConnsPerHost)
+	cfg.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	cfg.MaxConnsPerHost = c.MaxConnsPerHost
+	cfg.MaxHeaderSize = c.MaxHeaderSize
+	cfg.DisableHTTP2 = c.DisableHTTP2
+	return cfg
+}
+
 func (c *Config) SlogValue() slog.Level {
 	switch c.LogLevel {
 	case "debug":
@@ -111,6 +503,15 @@ func getEnvString(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -129,6 +530,15 @@ func getEnvInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -137,3 +547,55 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// stringList - значения флага, который можно указать несколько раз.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseResolve разбирает "host:port:addr[,addr]", см. dnscache.ParseOverride.
+func parseResolve(values []string) (map[string][]netip.Addr, error) {
+	res := map[string][]netip.Addr{}
+	for _, value := range values {
+		hostPort, addrs, err := dnscache.ParseOverride(value)
+		if err != nil {
+			return nil, err
+		}
+		res[hostPort] = addrs
+	}
+	return res, nil
+}
+
+// parseCIDRs разбирает список сетей через запятую, одиночный адрес считается сетью из одного адреса.
+func parseCIDRs(value string) ([]netip.Prefix, error) {
+	var res []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			res = append(res, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", item)
+		}
+		res = append(res, prefix.Masked())
+	}
+	return res, nil
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"mime"
+	"net/http"
+	urllib "net/url"
+	pathlib "path"
+	"strings"
+)
+
+// Classifiable - элемент, тип которого окончательно известен только после загрузки (по Content-Type).
+type Classifiable interface {
+	// Classify возвращает элемент, которым он оказался на самом деле (например, ссылка <a href> на pdf - это asset).
+	Classify() Queueable
+}
+
+func (p *Page) SetContentType(contentType string) {
+	p.ContentType = contentType
+}
+
+// Classify превращает страницу, которая оказалась не html, в asset: он не парсится и не трансформируется.
+func (p *Page) Classify() Queueable {
+	mediaType := detectMediaType(p.ContentType, p.Content)
+	if isHTMLMediaType(mediaType) {
+		return p
+	}
+
+	return &asset{
+		sourceURL:   p.URL,
+		Content:     p.Content,
+		SkippedOn:   p.SkippedOn,
+		contentType: mediaType,
+		linkPath:    p.mirrorOptions().linkSavePath(p.URL),
+		mirror:      p.Mirror,
+		notModified: p.NotModified,
+		meta:        p.Meta,
+	}
+}
+
+func (a *asset) SetContentType(contentType string) {
+	a.contentType = contentType
+}
+
+// detectMediaType берет тип из Content-Type, а если сервер его не прислал или прислал бесполезный - угадывает по содержимому.
+func detectMediaType(contentType string, content []byte) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(content))
+	}
+	return mediaType
+}
+
+func isHTMLMediaType(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// extraExtensionTypes дополняет встроенную таблицу mime, которая знает не все типы файлов, на которые ссылаются со страниц.
+var extraExtensionTypes = map[string]string{
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tgz":  "application/gzip",
+	".tar":  "application/x-tar",
+	".rar":  "application/vnd.rar",
+	".7z":   "application/x-7z-compressed",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".mp3":  "audio/mpeg",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".exe":  "application/vnd.microsoft.portable-executable",
+	".dmg":  "application/x-apple-diskimage",
+	".iso":  "application/x-iso9660-image",
+	".txt":  "text/plain",
+	".csv":  "text/csv",
+}
+
+// preferredExtensions - расширения для типов, у которых их несколько (mime.ExtensionsByType сортирует их по алфавиту).
+var preferredExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"text/plain":      ".txt",
+	"application/zip": ".zip",
+	"application/pdf": ".pdf",
+	"audio/mpeg":      ".mp3",
+	"video/mp4":       ".mp4",
+}
+
+func mediaTypeByExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	if t, ok := extraExtensionTypes[ext]; ok {
+		return t
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	return mediaType
+}
+
+func extensionByMediaType(mediaType string) string {
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	return exts[0]
+}
+
+// linkSavePath - путь, по которому сохраняется элемент, найденный по ссылке.
+// Тип содержимого до загрузки неизвестен, поэтому он угадывается по расширению: "/doc.pdf" - файл, "/about" - страница.
+// Если угадано неверно, ссылки переписываются после обхода (Relinker).
+func (o *MirrorOptions) linkSavePath(url *urllib.URL) string {
+	ext := pathlib.Ext(url.Path)
+	if ext != "" {
+		if mediaType := mediaTypeByExtension(ext); mediaType != "" && !isHTMLMediaType(mediaType) {
+			return o.resolveLocalSavePath(url, "", "")
+		}
+	}
+
+	return o.resolveLocalSavePath(url, "index", "html")
+}
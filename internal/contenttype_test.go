@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		content     string
+		wantPage    bool
+		wantPath    string
+		wantAliases []string
+	}{
+		{"html", "https://example.com/about", "text/html; charset=utf-8", "<html></html>", true, "/about.html", nil},
+		{"pdf_by_header", "https://example.com/docs/manual.pdf", "application/pdf", "%PDF-1.4", false, "/docs/manual.pdf", nil},
+		{"pdf_without_extension", "https://example.com/download", "application/pdf", "%PDF-1.4", false, "/download.pdf", []string{"/download.html"}},
+		{"sniffed_png", "https://example.com/logo", "", "\x89PNG\x0D\x0A\x1A\x0A", false, "/logo.png", []string{"/logo.html"}},
+		{"sniffed_html", "https://example.com/page", "application/octet-stream", "<!DOCTYPE html><html></html>", true, "/page.html", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, _ := NewPage(tt.url)
+			_ = page.SetContent([]byte(tt.content))
+			page.SetContentType(tt.contentType)
+
+			item := page.Classify()
+
+			if _, isPage := item.(*Page); isPage != tt.wantPage {
+				t.Fatalf("got page %v, want %v", isPage, tt.wantPage)
+			}
+
+			savable := item.(Savable)
+			if got := savable.ResolveRelativeSavePath(); got != tt.wantPath {
+				t.Errorf("got save path %q, want %q", got, tt.wantPath)
+			}
+
+			if tt.wantPage {
+				return
+			}
+
+			aliases := item.(Aliasable).GetAliasPaths()
+			if len(aliases) != len(tt.wantAliases) || (len(aliases) > 0 && aliases[0] != tt.wantAliases[0]) {
+				t.Errorf("got aliases %v, want %v", aliases, tt.wantAliases)
+			}
+		})
+	}
+}
+
+func TestLinkSavePath(t *testing.T) {
+	page, _ := NewPage("https://example.com/index.html")
+	_ = page.SetContent([]byte(`<a href="/files/report.pdf">pdf</a><a href="/files/archive.zip">zip</a><a href="/about">about</a>`))
+	if err := page.Parse(); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if err := page.Transform(); err != nil {
+		t.Fatalf("failed to transform: %v", err)
+	}
+
+	var got []string
+	for _, l := range page.Links {
+		for _, attr := range l.HTMLNode.Attr {
+			if attr.Key == "href" {
+				got = append(got, attr.Val)
+			}
+		}
+	}
+
+	assertAllUrlsFound(t, got, []string{"./files/report.pdf", "./files/archive.zip", "./about.html"})
+}
@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fetchIndexFile = "fetch.json"
+	originalsDir   = "originals"
+)
+
+// Revalidatable - элемент, который не изменился с прошлого обхода (ответ 304).
+// Его сохраненная копия не перезаписывается, а разбирается сохраненный оригинал (см. FetchIndex).
+type Revalidatable interface {
+	SetNotModified()
+	IsNotModified() bool
+}
+
+// FetchRecord - сведения о последней загрузке url, нужные для условного запроса при следующем обходе.
+type FetchRecord struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	// Hash - sha256 тела после декодирования (до Transform).
+	Hash      string    `json:"hash"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// Original - тело сохранено в originals: сохраненная страница уже изменена Transform, а ссылки нужно искать в исходной.
+	Original bool `json:"original,omitempty"`
+	// Path - сохраненный файл относительно output dir.
+	Path string `json:"path,omitempty"`
+}
+
+// Validators - значения для условного запроса.
+func (r FetchRecord) Validators() httpclient.Validators {
+	return httpclient.Validators{ETag: r.ETag, LastModified: r.LastModified}
+}
+
+// FetchIndex хранит FetchRecord между запусками в <state-dir>/fetch.json, а исходные тела разбираемых
+// элементов - в <state-dir>/originals/<hash>.
+//
+// Запись попадает в индекс (Commit) только после сохранения элемента: иначе следующий обход получит 304
+// для файла, которого нет. По той же причине Revalidate проверяет, что сохраненный файл на месте.
+type FetchIndex struct {
+	dir    string
+	output *safefs.Root
+
+	mu      sync.Mutex
+	records map[string]FetchRecord
+	pending map[string]FetchRecord
+}
+
+// LoadFetchIndex загружает индекс из stateDir, отсутствующий файл - пустой индекс.
+// output - копия, в которую сохранены элементы из индекса.
+func LoadFetchIndex(stateDir string, output *safefs.Root) (*FetchIndex, error) {
+	x := &FetchIndex{
+		dir:     stateDir,
+		output:  output,
+		records: map[string]FetchRecord{},
+		pending: map[string]FetchRecord{},
+	}
+
+	data, err := os.ReadFile(filepath.Join(stateDir, fetchIndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read fetch index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &x.records); err != nil {
+		return nil, fmt.Errorf("parse fetch index: %w", err)
+	}
+
+	return x, nil
+}
+
+// Save пишет индекс через временный файл, чтобы прерванная запись не испортила индекс.
+func (x *FetchIndex) Save() error {
+	x.mu.Lock()
+	data, err := json.MarshalIndent(x.records, "", "  ")
+	x.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("marshal fetch index: %w", err)
+	}
+
+	if err := os.MkdirAll(x.dir, 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	indexFile := filepath.Join(x.dir, fetchIndexFile)
+	tmpFile := indexFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("write fetch index: %w", err)
+	}
+
+	return os.Rename(tmpFile, indexFile)
+}
+
+// Revalidate возвращает запись для условного запроса. Запись не подходит, если в ней нет ETag и Last-Modified,
+// сохраненный файл пропал или изменен или элемент разбирался, а его оригинал не сохранился.
+func (x *FetchIndex) Revalidate(url string) (FetchRecord, bool) {
+	x.mu.Lock()
+	record, ok := x.records[url]
+	x.mu.Unlock()
+
+	if !ok || record.Validators().IsZero() {
+		return FetchRecord{}, false
+	}
+	if !x.isSaved(record) {
+		return FetchRecord{}, false
+	}
+	if record.Original {
+		if _, err := os.Stat(x.originalPath(record.Hash)); err != nil {
+			return FetchRecord{}, false
+		}
+	}
+
+	return record, true
+}
+
+// isSaved - файл элемента на месте. Файл разбираемого элемента изменен Transform, поэтому сверяется только
+// его наличие, остальные сохраняются как есть и сверяются по Hash.
+func (x *FetchIndex) isSaved(record FetchRecord) bool {
+	if record.Path == "" {
+		return false
+	}
+
+	if record.Original {
+		_, err := x.output.Stat(record.Path)
+		return err == nil
+	}
+
+	content, err := x.output.ReadFile(record.Path)
+	return err == nil && ContentHash(content) == record.Hash
+}
+
+// LoadOriginal читает сохраненный оригинал, nil - элемент не разбирается и оригинал не нужен.
+func (x *FetchIndex) LoadOriginal(record FetchRecord) ([]byte, error) {
+	if !record.Original {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(x.originalPath(record.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("read original: %w", err)
+	}
+	return data, nil
+}
+
+// Fetched запоминает результат загрузки до Commit.
+func (x *FetchIndex) Fetched(url string, record FetchRecord) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.pending[url] = record
+}
+
+// Commit переносит результат загрузки в индекс, когда элемент сохранен в path.
+// Пустой path - элемент не изменился и не перезаписывался, файл прежний.
+// original - тело до Transform для элементов, которые разбираются, nil для остальных.
+func (x *FetchIndex) Commit(url string, path string, original []byte) error {
+	x.mu.Lock()
+	record, ok := x.pending[url]
+	delete(x.pending, url)
+	x.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if path != "" {
+		record.Path = path
+	}
+	if original != nil {
+		if err := x.writeOriginal(record.Hash, original); err != nil {
+			return err
+		}
+		record.Original = true
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	previous, ok := x.records[url]
+	x.records[url] = record
+
+	if ok && previous.Original && previous.Hash != record.Hash && !x.isReferenced(previous.Hash) {
+		_ = os.Remove(x.originalPath(previous.Hash))
+	}
+
+	return nil
+}
+
+// Forget удаляет запись url, файл которого убран из копии: иначе при возвращении url получит 304 для файла, которого нет.
+func (x *FetchIndex) Forget(url string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	record, ok := x.records[url]
+	delete(x.records, url)
+	delete(x.pending, url)
+
+	if ok && record.Original && !x.isReferenced(record.Hash) {
+		_ = os.Remove(x.originalPath(record.Hash))
+	}
+}
+
+// isReferenced - одинаковое содержимое у разных url хранится одним файлом.
+func (x *FetchIndex) isReferenced(hash string) bool {
+	for _, record := range x.records {
+		if record.Original && record.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *FetchIndex) writeOriginal(hash string, content []byte) error {
+	path := x.originalPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	// the same content can be written concurrently for different urls
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create original: %w", err)
+	}
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write original: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write original: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (x *FetchIndex) originalPath(hash string) string {
+	return filepath.Join(x.dir, originalsDir, hash)
+}
+
+// ContentHash - sha256 тела в hex, см. FetchRecord.Hash.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
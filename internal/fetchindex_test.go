@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newOutput - копия с уже сохраненными файлами.
+func newOutput(t *testing.T, files map[string]string) *safefs.Root {
+	t.Helper()
+
+	output, err := safefs.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = output.Close() })
+
+	for name, content := range files {
+		if err := output.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return output
+}
+
+func TestFetchIndexRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	output := newOutput(t, map[string]string{"index.html": "<html>transformed</html>", "app.css": "body{}"})
+
+	index, err := LoadFetchIndex(dir, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := []byte("<html>v1</html>")
+	index.Fetched("https://example.com/", FetchRecord{ETag: `"v1"`, Hash: ContentHash(page), FetchedAt: time.Now()})
+	index.Fetched("https://example.com/app.css", FetchRecord{LastModified: "Mon, 02 Jan 2006 15:04:05 GMT", Hash: ContentHash([]byte("body{}"))})
+	index.Fetched("https://example.com/unsaved", FetchRecord{ETag: `"x"`, Hash: "x"})
+
+	if err := index.Commit("https://example.com/", "index.html", page); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Commit("https://example.com/app.css", "app.css", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFetchIndex(dir, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, ok := loaded.Revalidate("https://example.com/")
+	if !ok || record.ETag != `"v1"` || !record.Original {
+		t.Fatalf("unexpected page record %+v, %t", record, ok)
+	}
+	original, err := loaded.LoadOriginal(record)
+	if err != nil || string(original) != string(page) {
+		t.Fatalf("got original %q, %v", original, err)
+	}
+
+	if record, ok := loaded.Revalidate("https://example.com/app.css"); !ok || record.Original {
+		t.Errorf("unexpected asset record %+v, %t", record, ok)
+	}
+	if _, ok := loaded.Revalidate("https://example.com/unsaved"); ok {
+		t.Error("not committed record should not be revalidated")
+	}
+}
+
+func TestFetchIndexRevalidate(t *testing.T) {
+	dir := t.TempDir()
+	index, _ := LoadFetchIndex(dir, newOutput(t, map[string]string{"a": "a", "index.html": "<html></html>"}))
+
+	index.Fetched("https://example.com/no-validators", FetchRecord{Hash: ContentHash([]byte("a"))})
+	_ = index.Commit("https://example.com/no-validators", "a", nil)
+
+	if _, ok := index.Revalidate("https://example.com/no-validators"); ok {
+		t.Error("record without validators should not be revalidated")
+	}
+
+	v1, v2 := []byte("v1"), []byte("v2")
+	index.Fetched("https://example.com/", FetchRecord{ETag: `"v1"`, Hash: ContentHash(v1)})
+	_ = index.Commit("https://example.com/", "index.html", v1)
+	index.Fetched("https://example.com/", FetchRecord{ETag: `"v2"`, Hash: ContentHash(v2)})
+	_ = index.Commit("https://example.com/", "index.html", v2)
+
+	// the previous original is removed when the page changes
+	if _, err := os.Stat(filepath.Join(dir, originalsDir, ContentHash(v1))); !os.IsNotExist(err) {
+		t.Errorf("stale original is kept: %v", err)
+	}
+
+	// without the original the page can't be parsed, so it is downloaded again
+	if err := os.Remove(filepath.Join(dir, originalsDir, ContentHash(v2))); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index.Revalidate("https://example.com/"); ok {
+		t.Error("record without the original should not be revalidated")
+	}
+}
+
+func TestFetchIndexRevalidateSavedFile(t *testing.T) {
+	output := newOutput(t, map[string]string{"index.html": "<html>transformed</html>", "app.css": "body{}", "logo.png": "png"})
+	index, _ := LoadFetchIndex(t.TempDir(), output)
+
+	page := []byte("<html></html>")
+	index.Fetched("https://example.com/", FetchRecord{ETag: `"p"`, Hash: ContentHash(page)})
+	_ = index.Commit("https://example.com/", "index.html", page)
+	index.Fetched("https://example.com/app.css", FetchRecord{ETag: `"c"`, Hash: ContentHash([]byte("body{}"))})
+	_ = index.Commit("https://example.com/app.css", "app.css", nil)
+	index.Fetched("https://example.com/logo.png", FetchRecord{ETag: `"l"`, Hash: ContentHash([]byte("png"))})
+	_ = index.Commit("https://example.com/logo.png", "logo.png", nil)
+
+	// a not modified item isn't rewritten, its record keeps the saved path
+	record, _ := index.Revalidate("https://example.com/logo.png")
+	index.Fetched("https://example.com/logo.png", record)
+	_ = index.Commit("https://example.com/logo.png", "", nil)
+	if _, ok := index.Revalidate("https://example.com/logo.png"); !ok {
+		t.Error("not modified item should be revalidated")
+	}
+
+	// the saved page is transformed, so only its presence is checked
+	if _, ok := index.Revalidate("https://example.com/"); !ok {
+		t.Error("saved page should be revalidated")
+	}
+	if err := output.Remove("index.html"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index.Revalidate("https://example.com/"); ok {
+		t.Error("record of a removed page should not be revalidated")
+	}
+
+	// e.g. truncated by an interrupted crawl
+	if err := output.WriteFile("app.css", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index.Revalidate("https://example.com/app.css"); ok {
+		t.Error("record of a changed file should not be revalidated")
+	}
+}
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	urllib "net/url"
+	pathlib "path"
+	"strconv"
+	"strings"
+)
+
+const (
+	FilterAllow = "allow"
+	FilterDeny  = "deny"
+)
+
+// FilterRule - правило фильтрации загрузок.
+//
+// Правило выбирает элементы по типу (MIMETypes, Extensions - достаточно совпадения с любым, пустые - выбирают все),
+// а PathPrefixes, MinSize, MaxSize ограничивают выбранные элементы:
+//   - allow: выбранный элемент, не прошедший ограничения, отклоняется ("images up to 5MB", "pdf only under /docs");
+//   - deny: отклоняется выбранный элемент, прошедший ограничения ("video", "zip larger than 100MB").
+//
+// Применяется первое правило, выбравшее элемент. Элементы, которые не выбрало ни одно правило, разрешены.
+type FilterRule struct {
+	Name         string   `json:"name"`
+	Action       string   `json:"action"`
+	MIMETypes    []string `json:"mime_types"`
+	Extensions   []string `json:"extensions"`
+	PathPrefixes []string `json:"path_prefixes"`
+	MinSize      ByteSize `json:"min_size"`
+	MaxSize      ByteSize `json:"max_size"`
+}
+
+// FilterCandidate - то, что известно об элементе на момент проверки.
+type FilterCandidate struct {
+	Path      string
+	MediaType string
+	// Size - размер в байтах, -1 если неизвестен.
+	Size int64
+}
+
+// FilterRejection - ошибка загрузки, отклоненной правилом (не повторяется).
+type FilterRejection struct {
+	Rule   string
+	Stage  string
+	Reason string
+}
+
+func (e *FilterRejection) Error() string {
+	return fmt.Sprintf("rejected by filter rule %s on %s: %s", e.Rule, e.Stage, e.Reason)
+}
+
+type Filters []FilterRule
+
+// Check возвращает *FilterRejection, если элемент отклонен, stage - момент проверки для отчета (extension, head, get).
+func (f Filters) Check(c FilterCandidate, stage string) error {
+	for i, rule := range f {
+		if !rule.selects(c) {
+			continue
+		}
+
+		reason, ok := rule.constrains(c)
+
+		switch {
+		case rule.Action == FilterAllow && !ok:
+			return &FilterRejection{Rule: rule.describe(i), Stage: stage, Reason: reason}
+		case rule.Action == FilterDeny && ok:
+			return &FilterRejection{Rule: rule.describe(i), Stage: stage, Reason: "denied"}
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func (f Filters) validate() error {
+	for i, rule := range f {
+		if rule.Action != FilterAllow && rule.Action != FilterDeny {
+			return fmt.Errorf("filter rule %s: action must be allow or deny, got %q", rule.describe(i), rule.Action)
+		}
+		for _, pattern := range rule.MIMETypes {
+			if _, err := pathlib.Match(pattern, ""); err != nil {
+				return fmt.Errorf("filter rule %s: invalid mime type pattern %q", rule.describe(i), pattern)
+			}
+		}
+		if rule.MaxSize > 0 && rule.MinSize > rule.MaxSize {
+			return fmt.Errorf("filter rule %s: min_size is greater than max_size", rule.describe(i))
+		}
+	}
+	return nil
+}
+
+func (r FilterRule) selects(c FilterCandidate) bool {
+	if len(r.MIMETypes) == 0 && len(r.Extensions) == 0 {
+		return true
+	}
+
+	if c.MediaType != "" {
+		for _, pattern := range r.MIMETypes {
+			if ok, _ := pathlib.Match(pattern, c.MediaType); ok {
+				return true
+			}
+		}
+	}
+
+	ext := strings.ToLower(pathlib.Ext(c.Path))
+	if ext != "" {
+		for _, e := range r.Extensions {
+			if "."+strings.TrimPrefix(strings.ToLower(e), ".") == ext {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// constrains проверяет ограничения правила, неизвестный размер им удовлетворяет (проверится на следующем этапе).
+func (r FilterRule) constrains(c FilterCandidate) (string, bool) {
+	if len(r.PathPrefixes) > 0 && !hasAnyPathPrefix(c.Path, r.PathPrefixes) {
+		return fmt.Sprintf("path %q is out of %v", c.Path, r.PathPrefixes), false
+	}
+
+	if c.Size >= 0 {
+		if r.MinSize > 0 && c.Size < int64(r.MinSize) {
+			return fmt.Sprintf("size %d is less than %s", c.Size, r.MinSize), false
+		}
+		if r.MaxSize > 0 && c.Size > int64(r.MaxSize) {
+			return fmt.Sprintf("size %d exceeds %s", c.Size, r.MaxSize), false
+		}
+	}
+
+	return "", true
+}
+
+func (r FilterRule) describe(index int) string {
+	if r.Name != "" {
+		return fmt.Sprintf("%q", r.Name)
+	}
+	return fmt.Sprintf("#%d (%s)", index+1, r.Action)
+}
+
+func hasAnyPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ByteSize - размер в байтах, в json задается числом или строкой вида "512KB", "5MB", "1.5GB".
+type ByteSize int64
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   float64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+func ParseByteSize(s string) (ByteSize, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+
+	multiplier := 1.0
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return ByteSize(n * multiplier), nil
+}
+
+func (b ByteSize) String() string {
+	for _, unit := range byteSizeUnits {
+		if unit.size > 1 && int64(b)%int64(unit.size) == 0 && b != 0 {
+			return fmt.Sprintf("%d%s", int64(b)/int64(unit.size), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(b))
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("size must be a number or a string: %s", data)
+	}
+
+	size, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+
+	*b = size
+	return nil
+}
+
+// NewFilterCandidate собирает кандидата из url и заголовков ответа (если они уже есть).
+// Без Content-Type тип угадывается по расширению, size < 0 - размер неизвестен.
+func NewFilterCandidate(rawURL string, contentType string, size int64) FilterCandidate {
+	c := FilterCandidate{Size: size}
+
+	if u, err := urllib.Parse(rawURL); err == nil {
+		c.Path = u.Path
+	}
+
+	if contentType != "" {
+		c.MediaType, _, _ = mime.ParseMediaType(contentType)
+	}
+	if c.MediaType == "" {
+		c.MediaType = mediaTypeByExtension(pathlib.Ext(c.Path))
+	}
+
+	return c
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	var filters Filters
+	err := json.Unmarshal([]byte(`[
+		{"name": "images", "action": "allow", "mime_types": ["image/*"], "max_size": "5MB"},
+		{"action": "deny", "mime_types": ["video/*"]},
+		{"name": "docs", "action": "allow", "mime_types": ["application/pdf"], "path_prefixes": ["/docs"]}
+	]`), &filters)
+	if err != nil {
+		t.Fatalf("failed to unmarshal filters: %v", err)
+	}
+
+	if err := filters.validate(); err != nil {
+		t.Fatalf("want valid filters, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		url       string
+		mediaType string
+		size      int64
+		wantRule  string
+	}{
+		{"small_image", "https://example.com/logo.png", "", 1 << 10, ""},
+		{"large_image", "https://example.com/photo", "image/jpeg", 6 << 20, `"images"`},
+		{"image_unknown_size", "https://example.com/photo.jpg", "", -1, ""},
+		{"video_by_extension", "https://example.com/movie.mp4", "", -1, "#2 (deny)"},
+		{"pdf_under_docs", "https://example.com/docs/manual.pdf", "", -1, ""},
+		{"pdf_outside_docs", "https://example.com/docsx/manual.pdf", "", -1, `"docs"`},
+		{"page", "https://example.com/about", "text/html; charset=utf-8", 100, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := filters.Check(NewFilterCandidate(tt.url, tt.mediaType, tt.size), "test")
+
+			var rejection *FilterRejection
+			if !errors.As(err, &rejection) {
+				if tt.wantRule != "" {
+					t.Fatalf("want rejection by %s, got %v", tt.wantRule, err)
+				}
+				return
+			}
+
+			if rejection.Rule != tt.wantRule {
+				t.Errorf("got rejection by %s, want %q", rejection.Rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestFiltersValidate(t *testing.T) {
+	if err := (Filters{{Action: "skip"}}).validate(); err == nil {
+		t.Errorf("want error for unknown action")
+	}
+	if err := (Filters{{Action: FilterAllow, MinSize: 10, MaxSize: 5}}).validate(); err == nil {
+		t.Errorf("want error for invalid size range")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]ByteSize{
+		"100":   100,
+		"512KB": 512 << 10,
+		"5MB":   5 << 20,
+		"1.5GB": 3 << 29,
+		"10 mb": 10 << 20,
+	}
+
+	for in, want := range tests {
+		got, err := ParseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+
+	if _, err := ParseByteSize("five"); err == nil {
+		t.Errorf("want error for invalid size")
+	}
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/htmlparser"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"github.com/gallyamow/go-crawler/pkg/sri"
+	"golang.org/x/net/html"
+	urllib "net/url"
+	pathlib "path"
+	"strings"
+)
+
+// SRIPolicy определяет, что делать с integrity-атрибутами ресурсов в локальной копии.
+type SRIPolicy string
+
+const (
+	// SRIPolicyStrip удаляет integrity и crossorigin, локальная копия загружается всегда.
+	SRIPolicyStrip SRIPolicy = "strip"
+	// SRIPolicyRecompute пересчитывает integrity по сохраненным файлам после завершения обхода.
+	SRIPolicyRecompute SRIPolicy = "recompute"
+	// SRIPolicyKeep оставляет атрибуты как есть.
+	SRIPolicyKeep SRIPolicy = "keep"
+)
+
+func (p SRIPolicy) Valid() bool {
+	switch p {
+	case SRIPolicyStrip, SRIPolicyRecompute, SRIPolicyKeep:
+		return true
+	}
+	return false
+}
+
+// Verifiable - элемент, содержимое которого можно проверить после загрузки.
+type Verifiable interface {
+	VerifyIntegrity() error
+}
+
+func (a *asset) VerifyIntegrity() error {
+	if a.integrity == "" || sri.Verify(a.integrity, a.Content) {
+		return nil
+	}
+
+	return fmt.Errorf("integrity mismatch, expected %q", a.integrity)
+}
+
+// HasIntegrity проверяет, есть ли у сохраняемых ресурсов страницы integrity-атрибуты.
+func (p *Page) HasIntegrity() bool {
+	for _, a := range p.Assets {
+		if a.integrity != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RecomputeIntegrity пересчитывает integrity локальных ресурсов уже сохраненной страницы по файлам на диске.
+// Если файл ресурса не сохранен - атрибут удаляется, иначе браузер не загрузит ресурс.
+func RecomputeIntegrity(output *safefs.Root, pagePath string) error {
+	content, err := output.ReadFile(pagePath)
+	if err != nil {
+		return fmt.Errorf("read page: %w", err)
+	}
+
+	rootNode, resources, err := htmlparser.ParseHTMLResources(content)
+	if err != nil {
+		return err
+	}
+
+	for _, res := range resources {
+		integrity := htmlparser.ReadIntegrity(res.Node)
+		if integrity == "" {
+			continue
+		}
+
+		// only rewritten (local) resources, external ones are untouched and their hashes are still valid
+		srcURL, err := urllib.Parse(res.SourceURL)
+		if err != nil || srcURL.IsAbs() || strings.HasPrefix(res.SourceURL, "/") {
+			continue
+		}
+
+		// path.Join resolves "../" and never goes above the root
+		assetContent, err := output.ReadFile(pathlib.Join("/", pathlib.Dir(pagePath), srcURL.Path))
+		if err != nil {
+			htmlparser.StripIntegrity(res.Node)
+			continue
+		}
+
+		digest, err := sri.Compute(sri.Strongest(sri.Parse(integrity)), assetContent)
+		if err != nil {
+			htmlparser.StripIntegrity(res.Node)
+			continue
+		}
+
+		htmlparser.WriteIntegrity(res.Node, digest.String())
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, rootNode); err != nil {
+		return fmt.Errorf("failed to render page content: %v", err)
+	}
+
+	if err := output.WriteFile(pagePath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
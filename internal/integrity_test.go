@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"strings"
+	"testing"
+)
+
+const integrityPageHTML = `<html><head>
+<script src="/app.js" integrity="sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=" crossorigin="anonymous"></script>
+<link rel="stylesheet" href="/missing.css" integrity="sha384-AAAA">
+</head></html>`
+
+func TestIntegrity(t *testing.T) {
+	newParsedPage := func(t *testing.T, policy SRIPolicy) *Page {
+		page, _ := NewPage("https://example.com/index.html")
+		page.Mirror = &MirrorOptions{SRIPolicy: policy}
+		_ = page.SetContent([]byte(integrityPageHTML))
+		if err := page.Parse(); err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		return page
+	}
+
+	t.Run("verify", func(t *testing.T) {
+		page := newParsedPage(t, SRIPolicyStrip)
+
+		for _, a := range page.Assets {
+			// sha256 of empty content
+			_ = a.SetContent([]byte{})
+		}
+
+		if err := page.Assets[0].VerifyIntegrity(); err != nil {
+			t.Errorf("want valid integrity, got %v", err)
+		}
+		if err := page.Assets[1].VerifyIntegrity(); err == nil {
+			t.Errorf("want integrity mismatch")
+		}
+	})
+
+	t.Run("strip", func(t *testing.T) {
+		page := newParsedPage(t, SRIPolicyStrip)
+		if err := page.Transform(); err != nil {
+			t.Fatalf("failed to transform: %v", err)
+		}
+
+		content := string(page.GetContent())
+		if strings.Contains(content, "integrity") || strings.Contains(content, "crossorigin") {
+			t.Errorf("integrity attributes should be stripped: %s", content)
+		}
+	})
+
+	t.Run("recompute", func(t *testing.T) {
+		page := newParsedPage(t, SRIPolicyRecompute)
+		if !page.HasIntegrity() {
+			t.Fatalf("want page with integrity")
+		}
+
+		if err := page.Transform(); err != nil {
+			t.Fatalf("failed to transform: %v", err)
+		}
+
+		output, err := safefs.Open(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer output.Close()
+
+		pagePath := page.ResolveRelativeSavePath()
+		if err := output.WriteFile(pagePath, page.GetContent(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := output.WriteFile("app.js", []byte("transformed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := RecomputeIntegrity(output, pagePath); err != nil {
+			t.Fatalf("failed to recompute: %v", err)
+		}
+
+		content, _ := output.ReadFile(pagePath)
+
+		// echo -n transformed | openssl dgst -sha256 -binary | openssl base64 -A
+		if !strings.Contains(string(content), `integrity="sha256-ZRfq3arq8xod6bd8OihOWGL0x1rnvCziYcEMvq/cX6U="`) {
+			t.Errorf("integrity was not recomputed: %s", content)
+		}
+		if strings.Contains(string(content), "sha384-AAAA") {
+			t.Errorf("integrity of missing asset should be stripped: %s", content)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/htmlparser"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"net/http"
+	urllib "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginConfig - вход через html-форму перед обходом: GET формы (со всеми hidden-полями, в т.ч. CSRF-токеном),
+// подстановка Fields и отправка. Сессия хранится в cookie jar.
+type LoginConfig struct {
+	URL string `json:"url"`
+	// Form - селектор формы, см. htmlparser.FindForm. Пустой - первая форма с полем password.
+	Form    string                `json:"form"`
+	Fields  map[string]LoginField `json:"fields"`
+	Success LoginSuccess          `json:"success"`
+
+	values map[string]httpclient.Secret
+}
+
+// LoginField - значение поля формы: как есть (value) или секрет (env, file).
+type LoginField struct {
+	Value string `json:"value"`
+	SecretRef
+}
+
+// LoginSuccess - как понять, что вход удался. Всегда проверяется, что ответ не вернул на страницу входа.
+type LoginSuccess struct {
+	// Contains - текст, который есть на странице после входа (например, "Logout").
+	Contains string `json:"contains"`
+	// Cookie - cookie, которую сервер устанавливает после входа.
+	Cookie string `json:"cookie"`
+}
+
+// ErrLoginFailed - проверка LoginSuccess не прошла (неверные учетные данные, изменилась форма и т.п.).
+var ErrLoginFailed = errors.New("login failed")
+
+func (c *LoginConfig) resolve() error {
+	if c.URL == "" {
+		return fmt.Errorf("login: url cannot be empty")
+	}
+	if _, err := urllib.Parse(c.URL); err != nil {
+		return fmt.Errorf("login: invalid url: %w", err)
+	}
+
+	c.values = map[string]httpclient.Secret{}
+	for name, field := range c.Fields {
+		if field.Env == "" && field.File == "" {
+			c.values[name] = httpclient.Secret(field.Value)
+			continue
+		}
+		if field.Value != "" {
+			return fmt.Errorf("login: field %s: value cannot be combined with env or file", name)
+		}
+
+		value, err := field.SecretRef.resolve()
+		if err != nil {
+			return fmt.Errorf("login: field %s: %w", name, err)
+		}
+		c.values[name] = value
+	}
+
+	return nil
+}
+
+// Login выполняет вход и повторяет его, когда сессия истекает посреди обхода.
+type Login struct {
+	config   *LoginConfig
+	client   *httpclient.Client
+	jar      http.CookieJar
+	loginURL *urllib.URL
+
+	mu          sync.Mutex
+	lastLoginAt time.Time
+}
+
+func NewLogin(config *LoginConfig, client *httpclient.Client, jar http.CookieJar) (*Login, error) {
+	loginURL, err := urllib.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("login: invalid url: %w", err)
+	}
+
+	return &Login{config: config, client: client, jar: jar, loginURL: loginURL}, nil
+}
+
+// Run выполняет вход.
+func (l *Login) Run(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.run(ctx)
+}
+
+// Refresh повторяет вход, если после since никто другой его уже не повторил:
+// истекшую сессию одновременно обнаруживают несколько воркеров, а входить нужно один раз.
+func (l *Login) Refresh(ctx context.Context, since time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lastLoginAt.After(since) {
+		return nil
+	}
+	return l.run(ctx)
+}
+
+// IsLoginURL - ответ пришел со страницы входа, т.е. сервер перенаправил туда неавторизованный запрос.
+func (l *Login) IsLoginURL(u *urllib.URL) bool {
+	return u != nil && strings.EqualFold(u.Host, l.loginURL.Host) && strings.TrimSuffix(u.Path, "/") == strings.TrimSuffix(l.loginURL.Path, "/")
+}
+
+func (l *Login) run(ctx context.Context) error {
+	page, err := l.client.Get(ctx, l.loginURL.String())
+	if err != nil {
+		return fmt.Errorf("login: get form: %w", err)
+	}
+
+	form, err := htmlparser.FindForm(page.Content, l.config.Form)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	for name, value := range l.config.values {
+		form.Fields.Set(name, value.Reveal())
+	}
+
+	actionURL, err := page.URL.Parse(form.Action)
+	if err != nil {
+		return fmt.Errorf("login: invalid form action %q: %w", form.Action, err)
+	}
+
+	var resp *httpclient.Response
+	if form.Method == http.MethodGet {
+		actionURL.RawQuery = form.Fields.Encode()
+		resp, err = l.client.Get(ctx, actionURL.String())
+	} else {
+		resp, err = l.client.PostForm(ctx, actionURL.String(), form.Fields)
+	}
+	if err != nil {
+		return fmt.Errorf("login: submit form: %w", err)
+	}
+
+	if err := l.check(resp); err != nil {
+		return err
+	}
+
+	l.lastLoginAt = time.Now()
+	return nil
+}
+
+// check проверяет ответ на отправку формы. Ответ на странице входа - неудача, если форма ушла на нее редиректом
+// или других проверок нет: форма, отправленная на тот же url, может отдать 200 уже для вошедшего пользователя.
+func (l *Login) check(resp *httpclient.Response) error {
+	success := l.config.Success
+	explicit := success.Contains != "" || success.Cookie != ""
+	if l.IsLoginURL(resp.URL) && (len(resp.Redirects) > 0 || !explicit) {
+		return fmt.Errorf("%w: redirected back to the login page", ErrLoginFailed)
+	}
+
+	if success.Contains != "" && !bytes.Contains(resp.Content, []byte(success.Contains)) {
+		return fmt.Errorf("%w: response doesn't contain %q", ErrLoginFailed, success.Contains)
+	}
+
+	if success.Cookie != "" {
+		found := false
+		for _, cookie := range l.jar.Cookies(resp.URL) {
+			if cookie.Name == success.Cookie {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: cookie %s is not set", ErrLoginFailed, success.Cookie)
+		}
+	}
+
+	return nil
+}
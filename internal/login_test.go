@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"errors"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"net/http"
+	"net/http/httptest"
+	urllib "net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newLoginServer(t *testing.T, logins *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "csrf", Value: "tok"})
+		_, _ = w.Write([]byte(`<form method="post" action="/session">
+			<input type="hidden" name="csrf_token" value="tok">
+			<input name="user"><input type="password" name="pass">
+		</form>`))
+	})
+	mux.HandleFunc("POST /session", func(w http.ResponseWriter, r *http.Request) {
+		csrf, _ := r.Cookie("csrf")
+		if csrf == nil || r.FormValue("csrf_token") != csrf.Value || r.FormValue("user") != "crawler" || r.FormValue("pass") != "pa55" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		logins.Add(1)
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/"})
+		http.Redirect(w, r, "/docs", http.StatusSeeOther)
+	})
+	// the form is posted back to its own url, which renders either the form again or the logged-in page
+	mux.HandleFunc("GET /signin", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<form method="post"><input name="user"><input type="password" name="pass"></form>`))
+	})
+	mux.HandleFunc("POST /signin", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("user") != "crawler" || r.FormValue("pass") != "pa55" {
+			_, _ = w.Write([]byte(`<form method="post"><input name="user"><input type="password" name="pass"></form>`))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/"})
+		_, _ = w.Write([]byte("Welcome, <a href=/logout>Logout</a>"))
+	})
+	mux.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+		if sid, _ := r.Cookie("sid"); sid == nil {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("Welcome, <a href=/logout>Logout</a>"))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestLoginRun(t *testing.T) {
+	var logins atomic.Int32
+	srv := newLoginServer(t, &logins)
+	defer srv.Close()
+
+	t.Setenv("TEST_LOGIN_PASSWORD", "pa55")
+
+	tests := []struct {
+		name    string
+		path    string
+		pass    LoginField
+		success LoginSuccess
+		wantErr bool
+	}{
+		{"ok", "/login", LoginField{SecretRef: SecretRef{Env: "TEST_LOGIN_PASSWORD"}}, LoginSuccess{Contains: "Logout", Cookie: "sid"}, false},
+		{"wrong_password", "/login", LoginField{Value: "wrong"}, LoginSuccess{}, true},
+		{"missing_text", "/login", LoginField{Value: "pa55"}, LoginSuccess{Contains: "Dashboard"}, true},
+		{"same_url", "/signin", LoginField{Value: "pa55"}, LoginSuccess{Contains: "Logout"}, false},
+		{"same_url_wrong_password", "/signin", LoginField{Value: "wrong"}, LoginSuccess{Cookie: "sid"}, true},
+		// without success checks the login page in the response is the only sign of a failure
+		{"same_url_without_checks", "/signin", LoginField{Value: "pa55"}, LoginSuccess{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &LoginConfig{
+				URL:     srv.URL + tt.path,
+				Fields:  map[string]LoginField{"user": {Value: "crawler"}, "pass": tt.pass},
+				Success: tt.success,
+			}
+			if err := config.resolve(); err != nil {
+				t.Fatal(err)
+			}
+
+			jar, _ := httpclient.NewJar()
+			login, err := NewLogin(config, httpclient.NewClient(httpclient.WithCookieJar(jar)), jar)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = login.Run(t.Context())
+			if tt.wantErr {
+				if !errors.Is(err, ErrLoginFailed) {
+					t.Fatalf("want ErrLoginFailed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginRefresh(t *testing.T) {
+	var logins atomic.Int32
+	srv := newLoginServer(t, &logins)
+	defer srv.Close()
+
+	config := &LoginConfig{
+		URL:    srv.URL + "/login",
+		Fields: map[string]LoginField{"user": {Value: "crawler"}, "pass": {Value: "pa55"}},
+	}
+	if err := config.resolve(); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, _ := httpclient.NewJar()
+	login, _ := NewLogin(config, httpclient.NewClient(httpclient.WithCookieJar(jar)), jar)
+
+	requestedAt := time.Now()
+	for range 3 {
+		// concurrent workers which have seen the login page at the same time
+		if err := login.Refresh(t.Context(), requestedAt); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := logins.Load(); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+
+	loginURL, _ := urllib.Parse(srv.URL + "/login/")
+	if !login.IsLoginURL(loginURL) {
+		t.Errorf("want %s to be the login url", loginURL)
+	}
+}
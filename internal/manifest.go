@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	urllib "net/url"
+)
+
+// ManifestFile - web app manifest (<link rel="manifest">), который ссылается на иконки приложения.
+type ManifestFile struct {
+	asset
+	Icons []*asset
+}
+
+func (m *ManifestFile) Parse() error {
+	var doc map[string]any
+	if err := json.Unmarshal(m.Content, &doc); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	m.Icons = nil
+	walkManifestIcons(doc, func(icon map[string]any) {
+		src, ok := icon["src"].(string)
+		if !ok || src == "" {
+			return
+		}
+
+		srcURL, err := urllib.Parse(src)
+		if err != nil {
+			return
+		}
+
+		// icons are relative to the manifest, not to the page
+		srcURL.Fragment = ""
+		srcURL = m.sourceURL.ResolveReference(srcURL)
+
+		// skip icons from external domains
+		if srcURL.Host != m.sourceURL.Host {
+			return
+		}
+
+		m.Icons = append(m.Icons, &asset{sourceURL: srcURL, mirror: m.mirror, meta: m.meta.child(m.GetURL())})
+	})
+
+	return nil
+}
+
+func (m *ManifestFile) GetChildren() []Queueable {
+	var res []Queueable
+	for _, icon := range m.Icons {
+		res = append(res, icon)
+	}
+	return res
+}
+
+// Transform переписывает src иконок на пути относительно manifest-файла.
+func (m *ManifestFile) Transform() error {
+	var doc map[string]any
+	if err := json.Unmarshal(m.Content, &doc); err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	manifestPath := m.ResolveRelativeSavePath()
+
+	walkManifestIcons(doc, func(icon map[string]any) {
+		src, ok := icon["src"].(string)
+		if !ok {
+			return
+		}
+
+		srcURL, err := urllib.Parse(src)
+		if err != nil {
+			return
+		}
+
+		srcURL.Fragment = ""
+		srcURL = m.sourceURL.ResolveReference(srcURL)
+		if srcURL.Host != m.sourceURL.Host {
+			return
+		}
+
+		icon["src"] = makeRelativeURL(manifestPath, m.mirrorOptions().resolveLocalSavePath(srcURL, "", ""))
+	})
+
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to render manifest: %w", err)
+	}
+
+	m.Content = content
+	return nil
+}
+
+// walkManifestIcons вызывает fn для каждой иконки: icons[] и shortcuts[].icons[].
+func walkManifestIcons(doc map[string]any, fn func(icon map[string]any)) {
+	visit := func(v any) {
+		icons, _ := v.([]any)
+		for _, icon := range icons {
+			if iconObj, ok := icon.(map[string]any); ok {
+				fn(iconObj)
+			}
+		}
+	}
+
+	visit(doc["icons"])
+
+	shortcuts, _ := doc["shortcuts"].([]any)
+	for _, shortcut := range shortcuts {
+		if shortcutObj, ok := shortcut.(map[string]any); ok {
+			visit(shortcutObj["icons"])
+		}
+	}
+}
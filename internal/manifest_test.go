@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"encoding/json"
+	urllib "net/url"
+	"testing"
+)
+
+func TestManifestFile(t *testing.T) {
+	sourceURL, _ := urllib.Parse("https://example.com/static/site.webmanifest")
+	manifest := &ManifestFile{asset: asset{sourceURL: sourceURL}}
+
+	_ = manifest.SetContent([]byte(`{
+  "name": "Example",
+  "icons": [
+    {"src": "icons/192.png", "sizes": "192x192"},
+    {"src": "/img/512.png", "sizes": "512x512"},
+    {"src": "https://cdn.external.com/1.png"}
+  ],
+  "shortcuts": [{"name": "News", "icons": [{"src": "news.png"}]}]
+}`))
+
+	if err := manifest.Parse(); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	var got []string
+	for _, child := range manifest.GetChildren() {
+		got = append(got, child.ItemId())
+	}
+
+	assertAllUrlsFound(t, got, []string{
+		"https://example.com/static/icons/192.png",
+		"https://example.com/img/512.png",
+		"https://example.com/static/news.png",
+	})
+	assertAllUrlsNotFound(t, got, []string{"https://cdn.external.com/1.png"})
+
+	if err := manifest.Transform(); err != nil {
+		t.Fatalf("failed to transform: %v", err)
+	}
+
+	var doc struct {
+		Name  string `json:"name"`
+		Icons []struct {
+			Src string `json:"src"`
+		} `json:"icons"`
+	}
+	if err := json.Unmarshal(manifest.GetContent(), &doc); err != nil {
+		t.Fatalf("transformed manifest is invalid: %v", err)
+	}
+
+	want := []string{"./icons/192.png", "../img/512.png", "https://cdn.external.com/1.png"}
+	for i, icon := range doc.Icons {
+		if icon.Src != want[i] {
+			t.Errorf("icon %d: got %q, want %q", i, icon.Src, want[i])
+		}
+	}
+
+	if doc.Name != "Example" {
+		t.Errorf("other manifest fields should be kept, got name %q", doc.Name)
+	}
+}
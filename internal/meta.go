@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"net/http"
+	"time"
+)
+
+// MetaSuffix - sidecar лежит рядом с сохраненным файлом: index.html -> index.html.meta.json.
+const MetaSuffix = ".meta.json"
+
+// Traceable - элемент, для которого известно его происхождение (см. ItemMeta).
+type Traceable interface {
+	GetMeta() *ItemMeta
+}
+
+// ItemMeta - происхождение сохраненного файла: откуда и как он получен.
+// Referrer и Depth задает родитель, остальное - SetResponse после загрузки.
+type ItemMeta struct {
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url"`
+	// Redirects - адреса, которые вернули редирект, начиная с URL.
+	Redirects []string    `json:"redirects,omitempty"`
+	Status    int         `json:"status"`
+	Header    http.Header `json:"header"`
+	FetchedAt time.Time   `json:"fetched_at"`
+	// Hash - sha256 загруженного тела (до Transform), см. ContentHash.
+	Hash     string `json:"hash"`
+	Referrer string `json:"referrer,omitempty"`
+	// Depth - число переходов от стартовой страницы.
+	Depth int `json:"depth"`
+}
+
+// SetResponse запоминает ответ, куки (httpclient.CookieHeaders) не сохраняются: sidecar лежит в копии сайта рядом с файлами.
+func (m *ItemMeta) SetResponse(url string, resp *httpclient.Response, hash string, fetchedAt time.Time) {
+	m.URL = url
+	m.FinalURL = resp.URL.String()
+	m.Redirects = resp.Redirects
+	m.Status = resp.StatusCode
+	m.Header = resp.Header.Clone()
+	for _, name := range httpclient.CookieHeaders {
+		m.Header.Del(name)
+	}
+	m.FetchedAt = fetchedAt
+	m.Hash = hash
+}
+
+// IsFetched - элемент загружен в этом обходе (не пропущен и не 304).
+func (m *ItemMeta) IsFetched() bool {
+	return !m.FetchedAt.IsZero()
+}
+
+// child - происхождение элемента, найденного в этом.
+func (m *ItemMeta) child(referrer string) ItemMeta {
+	return ItemMeta{Referrer: referrer, Depth: m.Depth + 1}
+}
+
+// WriteSidecar пишет meta в <savePath>.meta.json.
+func (m *ItemMeta) WriteSidecar(output *safefs.Root, savePath string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal meta: %w", err)
+	}
+
+	if err := output.WriteFile(savePath+MetaSuffix, data, 0644); err != nil {
+		return fmt.Errorf("write meta: %w", err)
+	}
+	return nil
+}
+
+// ApplyLastModified ставит mtime файла по Last-Modified, без заголовка файл не меняется.
+func (m *ItemMeta) ApplyLastModified(output *safefs.Root, savePath string) error {
+	lastModified, err := http.ParseTime(m.Header.Get("Last-Modified"))
+	if err != nil {
+		return nil
+	}
+
+	if err := output.Chtimes(savePath, lastModified, lastModified); err != nil {
+		return fmt.Errorf("set mtime: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"encoding/json"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"net/http"
+	urllib "net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestItemMetaSidecar(t *testing.T) {
+	output, err := safefs.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	if err := output.WriteFile("a/index.html", []byte("<html></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	finalURL, _ := urllib.Parse("https://example.com/a/")
+	resp := &httpclient.Response{
+		URL:        finalURL,
+		Redirects:  []string{"https://example.com/a"},
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":  {"text/html"},
+			"Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+			"Set-Cookie":    {"session=secret"},
+			"Set-Cookie2":   {"legacy=secret"},
+		},
+	}
+
+	meta := ItemMeta{Referrer: "https://example.com/", Depth: 1}
+	meta.SetResponse("https://example.com/a", resp, "hash", time.Now())
+
+	if err := meta.WriteSidecar(output, "a/index.html"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := meta.ApplyLastModified(output, "a/index.html"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := output.ReadFile("a/index.html" + MetaSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ItemMeta
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.FinalURL != "https://example.com/a/" || len(got.Redirects) != 1 || got.Referrer != "https://example.com/" || got.Depth != 1 {
+		t.Errorf("unexpected sidecar %s", data)
+	}
+	if got.Header.Get("Set-Cookie") != "" || got.Header.Get("Set-Cookie2") != "" {
+		t.Error("cookies should not be written")
+	}
+
+	fi, err := os.Stat(filepath.Join(output.Dir(), "a", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC); !fi.ModTime().Equal(want) {
+		t.Errorf("got mtime %v, want %v", fi.ModTime(), want)
+	}
+}
+
+func TestItemMetaChildren(t *testing.T) {
+	page, _ := NewPage("https://example.com/")
+	page.Meta.Depth = 1
+	page.Content = []byte(`<html><head><link rel="stylesheet" href="/app.css"></head><body><a href="/about">about</a></body></html>`)
+
+	if err := page.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, child := range page.GetChildren() {
+		meta := child.(Traceable).GetMeta()
+		if meta.Referrer != "https://example.com/" || meta.Depth != 2 {
+			t.Errorf("%s: got referrer %q, depth %d", child.ItemId(), meta.Referrer, meta.Depth)
+		}
+	}
+}
@@ -16,6 +16,10 @@ type Metrics struct {
 	LinksDiscovered int64
 	AssetsFound     int64
 	BytesDownloaded int64
+	// BytesDecoded - размер тел после снятия Content-Encoding, BytesDownloaded - переданный по сети.
+	BytesDecoded int64
+	// NotModified - ответы 304 на условные запросы (элемент не изменился с прошлого обхода).
+	NotModified int64
 
 	// timing
 	StartTime     time.Time
@@ -65,6 +69,16 @@ func (m *Metrics) RecordBytesDownloaded(bytes int) {
 	atomic.AddInt64(&m.BytesDownloaded, int64(bytes))
 }
 
+// RecordTransfer учитывает тело ответа: encoded - передано по сети, decoded - после декодирования.
+func (m *Metrics) RecordTransfer(encoded int, decoded int) {
+	atomic.AddInt64(&m.BytesDownloaded, int64(encoded))
+	atomic.AddInt64(&m.BytesDecoded, int64(decoded))
+}
+
+func (m *Metrics) RecordNotModified() {
+	atomic.AddInt64(&m.NotModified, 1)
+}
+
 func (m *Metrics) RecordResponseTime(duration time.Duration) {
 	m.mu.Lock()
 	m.TotalResponseTime += duration
@@ -99,6 +113,8 @@ func (m *Metrics) GetStats() Stats {
 		LinksDiscovered:     atomic.LoadInt64(&m.LinksDiscovered),
 		AssetsFound:         atomic.LoadInt64(&m.AssetsFound),
 		BytesDownloaded:     atomic.LoadInt64(&m.BytesDownloaded),
+		BytesDecoded:        atomic.LoadInt64(&m.BytesDecoded),
+		NotModified:         atomic.LoadInt64(&m.NotModified),
 		StartTime:           m.StartTime,
 		LastCrawlTime:       m.LastCrawlTime,
 		AverageResponseTime: m.AverageResponseTime,
@@ -119,6 +135,8 @@ type Stats struct {
 	LinksDiscovered     int64
 	AssetsFound         int64
 	BytesDownloaded     int64
+	BytesDecoded        int64
+	NotModified         int64
 	StartTime           time.Time
 	LastCrawlTime       time.Time
 	AverageResponseTime time.Duration
@@ -133,8 +151,8 @@ type Stats struct {
 
 func (s Stats) String() string {
 	return fmt.Sprintf(
-		"Pages: %d crawled, %d failed | Links: %d | asset: %d | Bytes: %d | Workers: %d/%d | Uptime: %v | Avg Response: %v",
-		s.PagesCrawled, s.PagesFailed, s.LinksDiscovered, s.AssetsFound, s.BytesDownloaded,
+		"Pages: %d crawled, %d failed | Links: %d | asset: %d | Bytes: %d (%d decoded) | Workers: %d/%d | Uptime: %v | Avg Response: %v",
+		s.PagesCrawled, s.PagesFailed, s.LinksDiscovered, s.AssetsFound, s.BytesDownloaded, s.BytesDecoded,
 		s.ActiveWorkers, s.TotalWorkers, s.Uptime, s.AverageResponseTime,
 	)
 }
@@ -147,6 +165,14 @@ func (s Stats) SuccessRate() float64 {
 	return float64(s.PagesCrawled) / float64(total) * 100
 }
 
+// CompressionRatio - во сколько раз Content-Encoding уменьшил передаваемый объем.
+func (s Stats) CompressionRatio() float64 {
+	if s.BytesDownloaded == 0 {
+		return 0
+	}
+	return float64(s.BytesDecoded) / float64(s.BytesDownloaded)
+}
+
 func (s Stats) CrawlRate() float64 {
 	if s.Uptime.Seconds() == 0 {
 		return 0
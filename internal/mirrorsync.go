@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"os"
+	pathlib "path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	mirrorSyncFile = "sync.json"
+	quarantineDir  = "quarantine"
+)
+
+// SyncMode определяет, что делать с файлами, до которых последний полный обход не дошел.
+type SyncMode string
+
+const (
+	// SyncModeOff - файлы остаются, но по-прежнему отслеживаются, чтобы включенный позже sync их нашел.
+	SyncModeOff SyncMode = "off"
+	// SyncModePrune удаляет файлы.
+	SyncModePrune SyncMode = "prune"
+	// SyncModeQuarantine переносит файлы в <state-dir>/quarantine/<время обхода>/ с сохранением путей.
+	SyncModeQuarantine SyncMode = "quarantine"
+)
+
+func (m SyncMode) Valid() bool {
+	switch m {
+	case SyncModeOff, SyncModePrune, SyncModeQuarantine:
+		return true
+	}
+	return false
+}
+
+// SyncOptions - настройки MirrorSync.Sync.
+type SyncOptions struct {
+	Mode SyncMode
+	// DryRun только перечисляет устаревшие файлы.
+	DryRun bool
+	// MaxRatio - доля отслеживаемых файлов, больше которой за один обход не удаляется:
+	// так сломанный обход (сайт недоступен, изменилась разметка) не сотрет копию.
+	MaxRatio float64
+}
+
+// MirrorSync запоминает, какие файлы сохранены для каждого url, в <state-dir>/sync.json.
+// Устаревшими считаются файлы url, до которых полный обход не дошел, и прежние файлы url, которые он больше не создает.
+// Отслеживаются только файлы, созданные обходом, остальное содержимое OutputDir не трогается.
+type MirrorSync struct {
+	dir string
+
+	mu       sync.Mutex
+	previous map[string][]string
+	current  map[string][]string
+}
+
+// StaleFile - устаревший файл и url, для которого он был сохранен.
+type StaleFile struct {
+	URL  string
+	Path string
+}
+
+// LoadMirrorSync загружает файлы прошлых обходов из stateDir, отсутствующий файл - пустой список.
+func LoadMirrorSync(stateDir string) (*MirrorSync, error) {
+	s := &MirrorSync{
+		dir:      stateDir,
+		previous: map[string][]string{},
+		current:  map[string][]string{},
+	}
+
+	data, err := os.ReadFile(filepath.Join(stateDir, mirrorSyncFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read sync index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.previous); err != nil {
+		return nil, fmt.Errorf("parse sync index: %w", err)
+	}
+
+	return s, nil
+}
+
+// Produced запоминает файлы, сохраненные для url в этом обходе.
+func (s *MirrorSync) Produced(url string, paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current[url] = paths
+}
+
+// Kept - url обойден, но его файлы не перезаписывались (не изменился или не сохранился), прежние файлы остаются.
+func (s *MirrorSync) Kept(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if paths, ok := s.previous[url]; ok {
+		s.current[url] = paths
+	}
+}
+
+// Stale возвращает устаревшие файлы, отсортированные по пути.
+func (s *MirrorSync) Stale() []StaleFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a path can move to another url, e.g. when a page becomes an alias
+	produced := map[string]struct{}{}
+	for _, paths := range s.current {
+		for _, path := range paths {
+			produced[path] = struct{}{}
+		}
+	}
+
+	var stale []StaleFile
+	for url, paths := range s.previous {
+		for _, path := range paths {
+			if _, ok := produced[path]; !ok {
+				stale = append(stale, StaleFile{URL: url, Path: path})
+			}
+		}
+	}
+
+	slices.SortFunc(stale, func(a, b StaleFile) int {
+		return cmp.Or(cmp.Compare(a.Path, b.Path), cmp.Compare(a.URL, b.URL))
+	})
+	return stale
+}
+
+// KeepUnreached оставляет под наблюдением файлы, до которых этот обход не дошел: он неполный или sync выключен.
+func (s *MirrorSync) KeepUnreached() {
+	for _, file := range s.Stale() {
+		s.keep(file)
+	}
+}
+
+// Sync удаляет или переносит в карантин устаревшие файлы. Файлы, которые остались на месте (DryRun, превышен
+// MaxRatio, ошибка), остаются под наблюдением до следующего обхода.
+func (s *MirrorSync) Sync(output *safefs.Root, options SyncOptions, now time.Time) SyncRecord {
+	stale := s.Stale()
+	record := SyncRecord{Mode: options.Mode, DryRun: options.DryRun}
+	for _, file := range stale {
+		record.Stale = append(record.Stale, file.Path)
+	}
+
+	tracked := 0
+	s.mu.Lock()
+	for _, paths := range s.previous {
+		tracked += len(paths)
+	}
+	s.mu.Unlock()
+
+	switch {
+	case len(stale) == 0:
+		return record
+	case options.DryRun:
+		s.KeepUnreached()
+		return record
+	case float64(len(stale)) > options.MaxRatio*float64(tracked):
+		s.KeepUnreached()
+		record.Refused = fmt.Sprintf("%d of %d tracked files are stale, more than the %.0f%% limit", len(stale), tracked, options.MaxRatio*100)
+		return record
+	}
+
+	quarantine := pathlib.Join(StateDirName, quarantineDir, now.Format("20060102-150405"))
+	for _, file := range stale {
+		var err error
+		if options.Mode == SyncModeQuarantine {
+			err = output.Rename(file.Path, pathlib.Join(quarantine, file.Path))
+		} else {
+			err = output.Remove(file.Path)
+		}
+
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.keep(file)
+			record.Errors = append(record.Errors, ItemRecord{URL: file.URL, Message: err.Error()})
+			continue
+		}
+
+		record.Removed = append(record.Removed, file.Path)
+		removeEmptyDirs(output, pathlib.Dir(file.Path))
+	}
+
+	// urls which are neither reached nor kept because of errors are gone from the mirror
+	s.mu.Lock()
+	for _, file := range stale {
+		if _, ok := s.current[file.URL]; !ok && !slices.Contains(record.URLs, file.URL) {
+			record.URLs = append(record.URLs, file.URL)
+		}
+	}
+	s.mu.Unlock()
+	slices.Sort(record.URLs)
+
+	return record
+}
+
+// Save пишет список через временный файл, как FetchIndex.Save.
+func (s *MirrorSync) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.current, "", "  ")
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("marshal sync index: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	indexFile := filepath.Join(s.dir, mirrorSyncFile)
+	tmpFile := indexFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("write sync index: %w", err)
+	}
+
+	return os.Rename(tmpFile, indexFile)
+}
+
+func (s *MirrorSync) keep(file StaleFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slices.Contains(s.current[file.URL], file.Path) {
+		s.current[file.URL] = append(s.current[file.URL], file.Path)
+	}
+}
+
+// removeEmptyDirs удаляет опустевшие директории вверх до корня, Remove не удаляет непустые.
+func removeEmptyDirs(output *safefs.Root, dir string) {
+	for dir != "." && dir != "/" && output.Remove(dir) == nil {
+		dir = pathlib.Dir(dir)
+	}
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"slices"
+	"testing"
+	"time"
+)
+
+// crawlMirror сохраняет файлы url и возвращает MirrorSync следующего обхода.
+func crawlMirror(t *testing.T, output *safefs.Root, stateDir string, files map[string][]string) *MirrorSync {
+	t.Helper()
+
+	s, err := LoadMirrorSync(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, paths := range files {
+		for _, path := range paths {
+			if err := output.WriteFile(path, []byte(url), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Produced(url, paths)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := LoadMirrorSync(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return next
+}
+
+func TestMirrorSync(t *testing.T) {
+	files := map[string][]string{
+		"https://example.com/":     {"index.html", "index.html.meta.json"},
+		"https://example.com/a/":   {"a/index.html"},
+		"https://example.com/b":    {"b/index.html"},
+		"https://example.com/c":    {"c.html"},
+		"https://example.com/old":  {"old/deep/index.html"},
+		"https://example.com/same": {"same.css"},
+	}
+
+	tests := []struct {
+		name        string
+		options     SyncOptions
+		wantRemoved []string
+		wantURLs    []string
+		wantRefused bool
+	}{
+		{"prune", SyncOptions{Mode: SyncModePrune, MaxRatio: 0.5}, []string{"b/index.html", "index.html.meta.json", "old/deep/index.html"}, []string{"https://example.com/b", "https://example.com/old"}, false},
+		{"quarantine", SyncOptions{Mode: SyncModeQuarantine, MaxRatio: 0.5}, []string{"b/index.html", "index.html.meta.json", "old/deep/index.html"}, []string{"https://example.com/b", "https://example.com/old"}, false},
+		{"dry_run", SyncOptions{Mode: SyncModePrune, DryRun: true, MaxRatio: 0.5}, nil, nil, false},
+		{"threshold", SyncOptions{Mode: SyncModePrune, MaxRatio: 0.3}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := safefs.Open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer output.Close()
+			stateDir := t.TempDir()
+
+			s := crawlMirror(t, output, stateDir, files)
+
+			// the next crawl: sidecars are disabled, b and old are gone, c is not modified
+			s.Produced("https://example.com/", []string{"index.html"})
+			s.Produced("https://example.com/a/", []string{"a/index.html"})
+			s.Produced("https://example.com/same", []string{"same.css"})
+			s.Kept("https://example.com/c")
+
+			record := s.Sync(output, tt.options, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+
+			wantStale := []string{"b/index.html", "index.html.meta.json", "old/deep/index.html"}
+			if !slices.Equal(record.Stale, wantStale) {
+				t.Errorf("got stale %v, want %v", record.Stale, wantStale)
+			}
+			if !slices.Equal(record.Removed, tt.wantRemoved) || !slices.Equal(record.URLs, tt.wantURLs) {
+				t.Errorf("got removed %v of %v, want %v of %v", record.Removed, record.URLs, tt.wantRemoved, tt.wantURLs)
+			}
+			if (record.Refused != "") != tt.wantRefused {
+				t.Errorf("got refused %q", record.Refused)
+			}
+
+			for _, path := range wantStale {
+				_, err := output.ReadFile(path)
+				if removed := slices.Contains(tt.wantRemoved, path); removed != (err != nil) {
+					t.Errorf("%s: removed %t, read error %v", path, removed, err)
+				}
+			}
+			if _, err := output.ReadFile("c.html"); err != nil {
+				t.Errorf("kept file is removed: %v", err)
+			}
+			if len(tt.wantRemoved) > 0 {
+				if err := output.Remove("old"); err == nil {
+					t.Error("empty directories are kept")
+				}
+			}
+			if tt.options.Mode == SyncModeQuarantine {
+				if _, err := output.ReadFile(".crawler/quarantine/20250102-030405/b/index.html"); err != nil {
+					t.Errorf("file is not quarantined: %v", err)
+				}
+			}
+
+			// files which are not removed stay tracked
+			if err := s.Save(); err != nil {
+				t.Fatal(err)
+			}
+			next, _ := LoadMirrorSync(stateDir)
+			for _, path := range wantStale {
+				tracked := slices.ContainsFunc(next.Stale(), func(f StaleFile) bool { return f.Path == path })
+				if removed := slices.Contains(tt.wantRemoved, path); removed == tracked {
+					t.Errorf("%s: removed %t, tracked %t", path, removed, tracked)
+				}
+			}
+		})
+	}
+}
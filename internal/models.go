@@ -16,6 +16,7 @@ import (
 type Queueable interface {
 	ItemId() string
 	SetSkipped(onStage string)
+	GetSkippedOn() string
 }
 
 type Transformable interface {
@@ -30,6 +31,7 @@ type Savable interface {
 type Downloadable interface {
 	GetURL() string
 	SetContent(content []byte) error
+	SetContentType(contentType string)
 	GetSize() int
 }
 
@@ -38,13 +40,39 @@ type Parsable interface {
 	GetChildren() []Queueable
 }
 
+// MirrorOptions - настройки локальной копии, общие для всех страниц одного обхода.
+type MirrorOptions struct {
+	SRIPolicy SRIPolicy
+	// Paths - индекс путей, nil - пути строятся только из url (без разрешения конфликтов).
+	Paths *PathMapper
+}
+
+var defaultMirrorOptions = &MirrorOptions{
+	SRIPolicy: SRIPolicyStrip,
+}
+
 type Page struct {
-	URL       *urllib.URL
-	HTMLNode  *html.Node
-	Content   []byte
-	Links     []*Link
-	Assets    []*asset
-	SkippedOn string
+	URL         *urllib.URL
+	HTMLNode    *html.Node
+	Content     []byte
+	Links       []*Link
+	Assets      []*asset
+	SkippedOn   string
+	ContentType string
+	// Mirror передается дочерним страницам, nil - настройки по умолчанию.
+	Mirror *MirrorOptions
+	// NotModified - страница не изменилась с прошлого обхода, Content - ее сохраненный оригинал.
+	NotModified bool
+	Meta        ItemMeta
+	// Canonical - <link rel="canonical"> на том же хосте, nil - не указан.
+	Canonical *urllib.URL
+	// DuplicateOf - основная страница, дубликатом которой оказалась эта (см. PageDedup).
+	// Дубликат не разбирается дальше и сохраняется заглушкой с редиректом на основную.
+	DuplicateOf *urllib.URL
+	// NearDuplicateOf - url страницы с почти тем же текстом (см. NearDedup), страница все равно сохраняется.
+	NearDuplicateOf string
+	// ExternalRedirects - клиентские редиректы на другие хосты (переезд сайта): не обходятся, только попадают в отчет.
+	ExternalRedirects []*Link
 }
 
 func NewPage(rawURL string) (*Page, error) {
@@ -59,7 +87,7 @@ func NewPage(rawURL string) (*Page, error) {
 }
 
 func (p *Page) ResolveRelativeSavePath() string {
-	return resolveLocalSavePath(p.URL, "index", "html")
+	return p.mirrorOptions().resolveLocalSavePath(p.URL, "index", "html")
 }
 
 func (p *Page) GetContent() []byte {
@@ -87,14 +115,25 @@ func (p *Page) Transform() (err error) {
 		}
 	}()
 	pagePath := p.ResolveRelativeSavePath()
+	mirror := p.mirrorOptions()
+
+	if p.DuplicateOf != nil {
+		p.Content = RenderRedirectStub(pagePath, mirror.resolveLocalSavePath(p.DuplicateOf, "index", "html"))
+		return nil
+	}
 
 	for _, asset := range p.Assets {
 		newURL := makeRelativeURL(pagePath, asset.ResolveRelativeSavePath())
 		htmlparser.WriteResourceURL(asset.HTMLNode, newURL)
+
+		// SRIPolicyRecompute is applied after the crawl, when all assets are saved
+		if mirror.SRIPolicy == SRIPolicyStrip {
+			htmlparser.StripIntegrity(asset.HTMLNode)
+		}
 	}
 
 	for _, link := range p.Links {
-		newURL := makeRelativeURL(pagePath, resolveLocalSavePath(link.URL, "", "html"))
+		newURL := makeRelativeURL(pagePath, mirror.linkSavePath(link.URL))
 		htmlparser.WriteResourceURL(link.HTMLNode, newURL)
 	}
 
@@ -110,22 +149,49 @@ func (p *Page) Transform() (err error) {
 }
 
 func (p *Page) Parse() error {
+	// links of a duplicate are the links of its primary page
+	if p.DuplicateOf != nil {
+		return nil
+	}
+
 	rootNode, parsedResources, err := htmlparser.ParseHTMLResources(p.GetContent())
 
 	if err != nil {
 		return fmt.Errorf("failed to parse page content: %v", err)
 	}
 
-	links, assets := resolveLinksAndAssets(p.URL, parsedResources)
+	links, assets, externalRedirects := resolveLinksAndAssets(p.URL, parsedResources)
+	for _, a := range assets {
+		a.mirror = p.Mirror
+		a.meta = p.Meta.child(p.GetURL())
+	}
 
 	p.HTMLNode = rootNode
 	p.Links = links
 	p.Assets = assets
+	p.ExternalRedirects = externalRedirects
+	p.Canonical = resolveCanonicalURL(p.URL, rootNode)
 
 	return nil
 }
 
+// GetRedirects возвращает ссылки, на которые страница перенаправляет на стороне клиента, в т.ч. на другие хосты.
+func (p *Page) GetRedirects() []*Link {
+	var res []*Link
+	for _, l := range p.Links {
+		if l.Redirect != "" {
+			res = append(res, l)
+		}
+	}
+	return append(res, p.ExternalRedirects...)
+}
+
 func (p *Page) GetChildren() []Queueable {
+	// children of a duplicate are queued by its primary page
+	if p.DuplicateOf != nil {
+		return nil
+	}
+
 	var res []Queueable
 
 	// @idiomatic: interface slice conversion
@@ -136,16 +202,29 @@ func (p *Page) GetChildren() []Queueable {
 			// todo log
 			continue
 		}
+		page.Mirror = p.Mirror
+		page.Meta = p.Meta.child(p.GetURL())
 		res = append(res, page)
 	}
 
 	for _, a := range p.Assets {
+		if a.isManifest {
+			res = append(res, &ManifestFile{asset: *a})
+			continue
+		}
 		res = append(res, a)
 	}
 
 	return res
 }
 
+func (p *Page) mirrorOptions() *MirrorOptions {
+	if p.Mirror == nil {
+		return defaultMirrorOptions
+	}
+	return p.Mirror
+}
+
 func (p *Page) ItemId() string {
 	return p.GetURL()
 }
@@ -154,9 +233,28 @@ func (p *Page) SetSkipped(stage string) {
 	p.SkippedOn = stage
 }
 
+func (p *Page) GetSkippedOn() string {
+	return p.SkippedOn
+}
+
+func (p *Page) GetMeta() *ItemMeta {
+	return &p.Meta
+}
+
+func (p *Page) SetNotModified() {
+	p.NotModified = true
+}
+
+// IsNotModified - дубликат перезаписывается заглушкой, даже если прошлый обход сохранил его как страницу.
+func (p *Page) IsNotModified() bool {
+	return p.NotModified && p.DuplicateOf == nil
+}
+
 type Link struct {
 	URL      *urllib.URL
 	HTMLNode *html.Node
+	// Redirect - тип клиентского редиректа (meta refresh, javascript), пустой для обычных ссылок.
+	Redirect string
 }
 
 type CssFile struct {
@@ -170,10 +268,20 @@ type ImageFile struct {
 }
 
 type asset struct {
-	sourceURL *urllib.URL
-	HTMLNode  *html.Node
-	Content   []byte
-	SkippedOn string
+	sourceURL  *urllib.URL
+	HTMLNode   *html.Node
+	Content    []byte
+	SkippedOn  string
+	isManifest bool
+	// integrity копируется при парсинге: HTMLNode принадлежит странице и меняется при ее Transform.
+	integrity   string
+	contentType string
+	// linkPath - путь, на который ссылается родительская страница, если asset был найден как ссылка (см. Page.Classify).
+	linkPath string
+	mirror   *MirrorOptions
+	// notModified - asset не изменился с прошлого обхода, Content не загружался (кроме разбираемых, см. ManifestFile).
+	notModified bool
+	meta        ItemMeta
 }
 
 func (a *asset) GetURL() string {
@@ -185,7 +293,29 @@ func (a *asset) GetSize() int {
 }
 
 func (a *asset) ResolveRelativeSavePath() string {
-	return resolveLocalSavePath(a.sourceURL, "", "")
+	ext := ""
+
+	// extension-less asset found by a link: take the extension from the content type, parent pages are relinked after the crawl
+	// (see Relinker), the alias is for the pages which are not rewritten
+	if a.linkPath != "" && pathlib.Ext(a.sourceURL.Path) == "" {
+		ext = strings.TrimPrefix(extensionByMediaType(a.contentType), ".")
+	}
+
+	return a.mirrorOptions().resolveLocalSavePath(a.sourceURL, "", ext)
+}
+
+func (a *asset) mirrorOptions() *MirrorOptions {
+	if a.mirror == nil {
+		return defaultMirrorOptions
+	}
+	return a.mirror
+}
+
+func (a *asset) GetAliasPaths() []string {
+	if a.linkPath == "" || a.linkPath == a.ResolveRelativeSavePath() {
+		return nil
+	}
+	return []string{a.linkPath}
 }
 
 func (a *asset) GetContent() []byte {
@@ -205,29 +335,33 @@ func (a *asset) SetSkipped(stage string) {
 	a.SkippedOn = stage
 }
 
-func hasher(s string) string {
-	hash := md5.Sum([]byte(s))
-	return hex.EncodeToString(hash[:])
+func (a *asset) GetSkippedOn() string {
+	return a.SkippedOn
 }
 
-func resolveLocalSavePath(url *urllib.URL, fallbackName string, ext string) string {
-	dir := pathlib.Dir(url.Path)
-	name := pathlib.Base(url.Path)
+func (a *asset) GetMeta() *ItemMeta {
+	return &a.meta
+}
 
-	if name == "." || name == "/" {
-		name = fallbackName
-	}
+func (a *asset) SetNotModified() {
+	a.notModified = true
+}
 
-	if name == "" {
-		name = hasher(url.String())
-	}
+func (a *asset) IsNotModified() bool {
+	return a.notModified
+}
 
-	path := filepath.Join(dir, name)
-	if ext != "" {
-		path += "." + ext
-	}
-	return path
+func hasher(s string) string {
+	hash := md5.Sum([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
 
+// resolveLocalSavePath возвращает путь сохранения относительно OutputDir, см. buildSavePath.
+func (o *MirrorOptions) resolveLocalSavePath(url *urllib.URL, fallbackName string, ext string) string {
+	if o.Paths == nil {
+		return buildSavePath(url, fallbackName, ext)
+	}
+	return o.Paths.Map(url, fallbackName, ext)
 }
 
 func makeRelativeURL(rootPath, localPath string) string {
@@ -241,6 +375,9 @@ func makeRelativeURL(rootPath, localPath string) string {
 
 	// replace slashes
 	rel = strings.ReplaceAll(rel, string(filepath.Separator), "/")
+
+	// file names could contain "#", "%", spaces and so on
+	rel = (&urllib.URL{Path: rel}).EscapedPath()
 	if !strings.HasPrefix(rel, ".") {
 		rel = "./" + rel
 	}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"cmp"
+	"github.com/gallyamow/go-crawler/pkg/htmlparser"
+	"github.com/gallyamow/go-crawler/pkg/simhash"
+	"slices"
+	"sync"
+)
+
+// NearDedup находит почти одинаковые страницы (та же статья с другой сортировкой, версия для печати) по SimHash
+// видимого текста. Страница, не похожая ни на одну из предыдущих, начинает кластер, а похожие на нее на расстоянии
+// не больше maxDistance битов помечаются Page.NearDuplicateOf. Сравнение идет только с первыми страницами кластеров,
+// поэтому кластер не расползается цепочкой похожих страниц.
+type NearDedup struct {
+	mu    sync.Mutex
+	index *simhash.Index
+	// clusters - похожие страницы по url первой страницы кластера
+	clusters map[string][]NearDuplicateRecord
+}
+
+func NewNearDedup(maxDistance int) *NearDedup {
+	return &NearDedup{
+		index:    simhash.NewIndex(maxDistance),
+		clusters: map[string][]NearDuplicateRecord{},
+	}
+}
+
+// Check вызывается после Parse: страница почти дубликат, если ее текст похож на текст одной из предыдущих.
+// Страницы без текста не сравниваются: у всех пустых страниц один отпечаток.
+func (d *NearDedup) Check(page *Page) bool {
+	if page.HTMLNode == nil {
+		return false
+	}
+
+	text := htmlparser.VisibleText(page.HTMLNode)
+	if text == "" {
+		return false
+	}
+	fingerprint := simhash.Fingerprint(text)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	primary, distance, ok := d.index.Nearest(fingerprint)
+	if !ok {
+		d.index.Add(page.GetURL(), fingerprint)
+		return false
+	}
+
+	page.NearDuplicateOf = primary
+	d.clusters[primary] = append(d.clusters[primary], NearDuplicateRecord{URL: page.GetURL(), Distance: distance})
+	return true
+}
+
+// Clusters возвращает кластеры, отсортированные по url первой страницы, страницы в них - по url.
+func (d *NearDedup) Clusters() []NearDuplicateCluster {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var clusters []NearDuplicateCluster
+	for primary, pages := range d.clusters {
+		pages = slices.Clone(pages)
+		slices.SortFunc(pages, func(a, b NearDuplicateRecord) int {
+			return cmp.Compare(a.URL, b.URL)
+		})
+		clusters = append(clusters, NearDuplicateCluster{Primary: primary, Pages: pages})
+	}
+
+	slices.SortFunc(clusters, func(a, b NearDuplicateCluster) int {
+		return cmp.Compare(a.Primary, b.Primary)
+	})
+	return clusters
+}
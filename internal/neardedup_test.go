@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNearDedup(t *testing.T) {
+	var article strings.Builder
+	for i := range 50 {
+		fmt.Fprintf(&article, "<p>Paragraph %d tells how the crawler handles case %d of %d.</p>", i, i*3, i*11)
+	}
+
+	// in the crawl order
+	pages := []struct {
+		url  string
+		body string
+	}{
+		{"https://example.com/article", article.String()},
+		{"https://example.com/article?sort=desc", article.String() + "<p>Sorted by date</p>"},
+		{"https://example.com/about", "<p>We are a small team making tools for offline reading of sites since 2010.</p>"},
+		{"https://example.com/article?print=1", "<script>print()</script>" + article.String()},
+		{"https://example.com/empty", "<script>var app = 1;</script>"},
+		{"https://example.com/empty?utm_source=x", "<style>p {}</style>"},
+	}
+
+	dedup := NewNearDedup(3)
+	for _, tt := range pages {
+		page := newMirrorPage(t, nil, tt.url, "<html><body>"+tt.body+"</body></html>")
+		if err := page.Parse(); err != nil {
+			t.Fatal(err)
+		}
+
+		isNear := dedup.Check(page)
+		wantNear := strings.HasPrefix(tt.url, "https://example.com/article?")
+		if isNear != wantNear || (isNear && page.NearDuplicateOf != "https://example.com/article") {
+			t.Errorf("%s: got near-duplicate %t of %q", tt.url, isNear, page.NearDuplicateOf)
+		}
+	}
+
+	clusters := dedup.Clusters()
+	if len(clusters) != 1 || clusters[0].Primary != "https://example.com/article" {
+		t.Fatalf("got clusters %+v", clusters)
+	}
+
+	var got []string
+	for _, record := range clusters[0].Pages {
+		got = append(got, record.URL)
+	}
+	if want := []string{"https://example.com/article?print=1", "https://example.com/article?sort=desc"}; !slices.Equal(got, want) {
+		t.Errorf("got pages %v, want %v", got, want)
+	}
+}
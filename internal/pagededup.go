@@ -0,0 +1,153 @@
+package internal
+
+import (
+	urllib "net/url"
+	"sync"
+)
+
+// PageDedupMode определяет, какие страницы считаются одной и той же страницей (см. PageDedup).
+type PageDedupMode string
+
+const (
+	// PageDedupOff - каждая страница сохраняется и разбирается.
+	PageDedupOff PageDedupMode = "off"
+	// PageDedupExact - страницы с одинаковым телом.
+	PageDedupExact PageDedupMode = "exact"
+	// PageDedupCanonical - еще и страницы с одним <link rel="canonical">.
+	PageDedupCanonical PageDedupMode = "canonical"
+)
+
+func (m PageDedupMode) Valid() bool {
+	switch m {
+	case PageDedupOff, PageDedupExact, PageDedupCanonical:
+		return true
+	}
+	return false
+}
+
+// Причины, по которым страница стала алиасом, см. PageAliasRecord.
+const (
+	PageAliasContent   = "content"
+	PageAliasCanonical = "canonical"
+)
+
+// PageDedup находит страницы, которые уже обходятся под другим url: с тем же телом (трекинговые параметры,
+// /index.html и /) или с тем же canonical url. Основной становится первая обработанная из них, а по canonical -
+// страница по самому canonical url, даже если вариант обработан раньше. Остальные помечаются Page.DuplicateOf:
+// их дочерние элементы не ставятся в очередь, а вместо них сохраняются заглушки.
+type PageDedup struct {
+	mode PageDedupMode
+
+	mu sync.Mutex
+	// hashes - основная страница по sha256 тела и базе ссылок, canonicals - по canonical url
+	hashes     map[string]*urllib.URL
+	canonicals map[string]*urllib.URL
+	// aliases - основная страница дубликата, основная по телу может оказаться дубликатом по canonical
+	aliases map[string]*urllib.URL
+}
+
+func NewPageDedup(mode PageDedupMode) *PageDedup {
+	return &PageDedup{
+		mode:       mode,
+		hashes:     map[string]*urllib.URL{},
+		canonicals: map[string]*urllib.URL{},
+		aliases:    map[string]*urllib.URL{},
+	}
+}
+
+// ByContent вызывается после загрузки: страница - дубликат, если страница с тем же телом уже загружена
+// и относительные ссылки в ней ведут туда же. Поэтому /docs и /docs/ - разные страницы, а /?utm_source=x и
+// /index.html - дубликаты /.
+func (d *PageDedup) ByContent(page *Page) bool {
+	hash := ContentHash(page.Content) + " " + linkBase(page.URL)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	primary, ok := d.hashes[hash]
+	if !ok {
+		d.hashes[hash] = page.URL
+		return false
+	}
+
+	d.markDuplicate(page, primary)
+	return true
+}
+
+// ByCanonical вызывается после Parse: страница - дубликат, если ее canonical url уже занят другой страницей.
+// Страница по самому canonical url (или без canonical) не дубликат: она занимает url у варианта, обработанного
+// раньше, и следующие дубликаты ссылаются уже на нее. Вариант к этому времени сохранен полностью и остается.
+func (d *PageDedup) ByCanonical(page *Page) bool {
+	if d.mode != PageDedupCanonical || page.DuplicateOf != nil {
+		return false
+	}
+
+	own := canonicalKey(page.URL)
+	key := own
+	if page.Canonical != nil {
+		key = canonicalKey(page.Canonical)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	primary, ok := d.canonicals[key]
+	switch {
+	case !ok:
+		d.canonicals[key] = page.URL
+		if _, ok := d.canonicals[own]; !ok {
+			d.canonicals[own] = page.URL
+		}
+		return false
+	case primary.String() == page.URL.String():
+		return false
+	case key == own:
+		d.canonicals[key] = page.URL
+		d.repoint(primary, page.URL)
+		return false
+	}
+
+	d.markDuplicate(page, primary)
+	return true
+}
+
+// repoint делает primary основной вместо from для следующих дубликатов.
+func (d *PageDedup) repoint(from *urllib.URL, primary *urllib.URL) {
+	for alias, target := range d.aliases {
+		if target.String() == from.String() {
+			d.aliases[alias] = primary
+		}
+	}
+	d.aliases[from.String()] = primary
+}
+
+// markDuplicate ссылается на основную страницу, итоговую на момент вызова. Заглушка, сохраненная раньше, может
+// указывать на страницу, которая потом сама стала заглушкой (основная по телу оказалась дубликатом по canonical):
+// редиректы тогда идут цепочкой, но приводят к основной.
+func (d *PageDedup) markDuplicate(page *Page, primary *urllib.URL) {
+	for {
+		next, ok := d.aliases[primary.String()]
+		if !ok {
+			break
+		}
+		primary = next
+	}
+
+	page.DuplicateOf = primary
+	d.aliases[page.GetURL()] = primary
+}
+
+// linkBase - директория url, от которой разрешаются относительные ссылки страницы.
+func linkBase(url *urllib.URL) string {
+	return url.ResolveReference(&urllib.URL{Path: "./"}).String()
+}
+
+// canonicalKey - "https://example.com" и "https://example.com/" - один url.
+func canonicalKey(url *urllib.URL) string {
+	key := *url
+	key.Fragment = ""
+	if key.Path == "" {
+		key.Path = "/"
+	}
+	return key.String()
+}
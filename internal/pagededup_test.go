@@ -0,0 +1,151 @@
+package internal
+
+import "testing"
+
+func newMirrorPage(t *testing.T, mirror *MirrorOptions, rawURL string, content string) *Page {
+	t.Helper()
+
+	page, err := NewPage(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = page.SetContent([]byte(content))
+	page.Mirror = mirror
+	return page
+}
+
+func TestPageDedup(t *testing.T) {
+	const home = `<html><head><link rel="canonical" href="/"></head><body><a href="/about">About</a></body></html>`
+
+	dedup := NewPageDedup(PageDedupCanonical)
+	// "/" and "/index.html" are saved to different files only with the path index
+	mirror := &MirrorOptions{SRIPolicy: SRIPolicyStrip, Paths: NewPathMapper()}
+
+	root := newMirrorPage(t, mirror, "https://example.com", home)
+	tracking := newMirrorPage(t, mirror, "https://example.com/?utm_source=mail", home)
+	index := newMirrorPage(t, mirror, "https://example.com/index.html", home+"<!-- rendered at 12:00 -->")
+	about := newMirrorPage(t, mirror, "https://example.com/about", `<html><body><a href="/">Home</a></body></html>`)
+
+	for _, page := range []*Page{root, tracking, index, about} {
+		if dedup.ByContent(page) {
+			continue
+		}
+		if err := page.Parse(); err != nil {
+			t.Fatal(err)
+		}
+		dedup.ByCanonical(page)
+	}
+
+	tests := []struct {
+		page        *Page
+		wantPrimary string
+	}{
+		{root, ""},
+		{tracking, "https://example.com"},
+		{index, "https://example.com"},
+		{about, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if tt.page.DuplicateOf != nil {
+			got = tt.page.DuplicateOf.String()
+		}
+		if got != tt.wantPrimary {
+			t.Errorf("%s: got primary %q, want %q", tt.page.URL, got, tt.wantPrimary)
+		}
+	}
+
+	if children := tracking.GetChildren(); len(children) != 0 {
+		t.Errorf("a duplicate has %d children", len(children))
+	}
+	if children := root.GetChildren(); len(children) != 1 {
+		t.Errorf("the primary page has %d children, want 1", len(children))
+	}
+
+	if err := index.Transform(); err != nil {
+		t.Fatal(err)
+	}
+	want := string(RenderRedirectStub(index.ResolveRelativeSavePath(), root.ResolveRelativeSavePath()))
+	if got := string(index.GetContent()); got != want || index.ResolveRelativeSavePath() == root.ResolveRelativeSavePath() {
+		t.Errorf("duplicate is not a redirect stub: %s", got)
+	}
+}
+
+func TestPageDedupCanonicalFirst(t *testing.T) {
+	const home = `<html><head><link rel="canonical" href="/"></head><body><a href="/about">About</a></body></html>`
+
+	dedup := NewPageDedup(PageDedupCanonical)
+	mirror := &MirrorOptions{SRIPolicy: SRIPolicyStrip, Paths: NewPathMapper()}
+
+	// the variant is crawled before the page at its canonical url
+	variant := newMirrorPage(t, mirror, "https://example.com/?sort=asc", home+"<!-- sorted -->")
+	root := newMirrorPage(t, mirror, "https://example.com/", home)
+	other := newMirrorPage(t, mirror, "https://example.com/?sort=desc", home+"<!-- sorted desc -->")
+	copied := newMirrorPage(t, mirror, "https://example.com/?sort=asc&utm_source=mail", home+"<!-- sorted -->")
+
+	for _, page := range []*Page{variant, root, other, copied} {
+		if dedup.ByContent(page) {
+			continue
+		}
+		if err := page.Parse(); err != nil {
+			t.Fatal(err)
+		}
+		dedup.ByCanonical(page)
+	}
+
+	tests := []struct {
+		page        *Page
+		wantPrimary string
+	}{
+		{variant, ""},
+		{root, ""},
+		{other, "https://example.com/"},
+		// a duplicate of the variant by content goes to the canonical page too
+		{copied, "https://example.com/"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if tt.page.DuplicateOf != nil {
+			got = tt.page.DuplicateOf.String()
+		}
+		if got != tt.wantPrimary {
+			t.Errorf("%s: got primary %q, want %q", tt.page.URL, got, tt.wantPrimary)
+		}
+	}
+}
+
+func TestPageDedupLinkBase(t *testing.T) {
+	const docs = `<html><body><a href="intro.html">Intro</a></body></html>`
+
+	dedup := NewPageDedup(PageDedupExact)
+
+	// the same body, but "intro.html" is /intro.html on the first page and /docs/intro.html on the others
+	file := newMirrorPage(t, nil, "https://example.com/docs", docs)
+	dir := newMirrorPage(t, nil, "https://example.com/docs/", docs)
+	tracking := newMirrorPage(t, nil, "https://example.com/docs/?utm_source=mail", docs)
+
+	if dedup.ByContent(file) || dedup.ByContent(dir) {
+		t.Fatal("pages with different link bases are duplicates")
+	}
+	if !dedup.ByContent(tracking) || tracking.DuplicateOf.String() != "https://example.com/docs/" {
+		t.Errorf("got primary %v, want https://example.com/docs/", tracking.DuplicateOf)
+	}
+}
+
+func TestPageDedupExact(t *testing.T) {
+	dedup := NewPageDedup(PageDedupExact)
+
+	a := newMirrorPage(t, nil, "https://example.com/a", `<html><head><link rel="canonical" href="/"></head></html>`)
+	b := newMirrorPage(t, nil, "https://example.com/b", `<html><head><link rel="canonical" href="/"></head><body>b</body></html>`)
+	for _, page := range []*Page{a, b} {
+		if dedup.ByContent(page) {
+			t.Fatalf("%s is a duplicate by content", page.URL)
+		}
+		if err := page.Parse(); err != nil {
+			t.Fatal(err)
+		}
+		if dedup.ByCanonical(page) {
+			t.Errorf("%s is a duplicate by canonical in the exact mode", page.URL)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package internal
 
 import (
 	"github.com/gallyamow/go-crawler/pkg/htmlparser"
+	"golang.org/x/net/html"
 	urllib "net/url"
+	"strings"
 )
 
 // Parse парсит контент страницы, нормализует ссылки и сохраняет их вместе с оригинальными значениями.
@@ -25,9 +27,12 @@ import (
 //	return page, nil
 //}
 
-func resolveLinksAndAssets(pageURL *urllib.URL, htmlResources []*htmlparser.HTMLResource) ([]*Link, []*asset) {
+// resolveLinksAndAssets возвращает ссылки и ресурсы страницы на ее хосте, а клиентские редиректы на другие хосты -
+// отдельно: они не обходятся, но попадают в отчет.
+func resolveLinksAndAssets(pageURL *urllib.URL, htmlResources []*htmlparser.HTMLResource) ([]*Link, []*asset, []*Link) {
 	var links []*Link
 	var assets []*asset
+	var externalRedirects []*Link
 
 	for _, hr := range htmlResources {
 		srcURL, err := urllib.Parse(hr.SourceURL)
@@ -43,28 +48,55 @@ func resolveLinksAndAssets(pageURL *urllib.URL, htmlResources []*htmlparser.HTML
 
 		// проверять можно только после ResolveReference
 		if srcURL.Host != pageURL.Host {
+			if hr.Redirect != "" {
+				externalRedirects = append(externalRedirects, &Link{HTMLNode: hr.Node, URL: srcURL, Redirect: hr.Redirect})
+			}
 			continue
 		}
 
-		if hr.Tag() == "a" {
+		if hr.Tag() == "a" || hr.Redirect != "" {
 			// skip resources from external domains
-			if hr.Tag() == "a" && srcURL.Host != "" && srcURL.Host != pageURL.Host {
+			if srcURL.Host != "" && srcURL.Host != pageURL.Host {
 				continue
 			}
 
 			links = append(links, &Link{
 				HTMLNode: hr.Node,
 				URL:      srcURL,
+				Redirect: hr.Redirect,
 			})
 		} else {
 			assets = append(assets, &asset{
-				HTMLNode:  hr.Node,
-				sourceURL: srcURL,
+				HTMLNode:   hr.Node,
+				sourceURL:  srcURL,
+				isManifest: hr.Tag() == "link" && hr.HasRel("manifest"),
+				integrity:  htmlparser.ReadIntegrity(hr.Node),
 			})
 		}
 	}
 
-	return links, assets
+	return links, assets, externalRedirects
+}
+
+// resolveCanonicalURL возвращает абсолютный canonical url страницы, на другой хост страница не ссылается как на себя.
+func resolveCanonicalURL(pageURL *urllib.URL, rootNode *html.Node) *urllib.URL {
+	href, ok := htmlparser.FindCanonicalURL(rootNode)
+	if !ok {
+		return nil
+	}
+
+	canonicalURL, err := urllib.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil
+	}
+
+	canonicalURL.Fragment = ""
+	canonicalURL = pageURL.ResolveReference(canonicalURL)
+	if canonicalURL.Host != pageURL.Host {
+		return nil
+	}
+
+	return canonicalURL
 }
 
 //// Transform
@@ -3,6 +3,7 @@ package internal
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -19,6 +20,11 @@ func TestParse(t *testing.T) {
 		page, _ := NewPage(testUrl)
 
 		err = page.SetContent(content)
+		if err != nil {
+			t.Fatalf("failed to set test page content %q: %v", testUrl, err)
+		}
+
+		err = page.Parse()
 		if err != nil {
 			t.Fatalf("failed to parse test page %q: %v", testUrl, err)
 		}
@@ -47,7 +53,7 @@ func TestParse(t *testing.T) {
 
 		gotAssets := []string{}
 		for _, l := range page.Assets {
-			gotLinks = append(gotAssets, l.GetURL())
+			gotAssets = append(gotAssets, l.GetURL())
 		}
 
 		internalCss := []string{
@@ -118,3 +124,38 @@ func assertAllUrlsNotFound(t *testing.T, got []string, want []string) {
 		}
 	}
 }
+
+func TestParseRedirects(t *testing.T) {
+	page, _ := NewPage("https://example.com/old/index.html")
+	_ = page.SetContent([]byte(`<html><head>
+<meta http-equiv="refresh" content="0;url=/new/">
+<script>window.location.href = "https://example.com/js-target.html";</script>
+<script>location.replace("https://external.com/")</script>
+</head></html>`))
+
+	if err := page.Parse(); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	var got []string
+	for _, l := range page.GetRedirects() {
+		got = append(got, l.URL.String())
+	}
+
+	// the redirect to another host is reported, but not crawled
+	assertAllUrlsFound(t, got, []string{"https://example.com/new/", "https://example.com/js-target.html", "https://external.com/"})
+
+	var children []string
+	for _, child := range page.GetChildren() {
+		children = append(children, child.ItemId())
+	}
+	assertAllUrlsNotFound(t, children, []string{"https://external.com/"})
+
+	if err := page.Transform(); err != nil {
+		t.Fatalf("failed to transform: %v", err)
+	}
+
+	if !strings.Contains(string(page.GetContent()), `content="0;url=../new/`) {
+		t.Errorf("meta refresh was not rewritten: %s", page.GetContent())
+	}
+}
@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	urllib "net/url"
+	"os"
+	pathlib "path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// StateDirName - служебная директория внутри OutputDir (индексы, метаданные), страницы туда не сохраняются.
+	StateDirName = ".crawler"
+
+	maxSegmentLen = 100
+	shortHashLen  = 8
+)
+
+// windowsReservedNames - имена, которые нельзя использовать на Windows (с любым расширением).
+var windowsReservedNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// PathMapper сопоставляет url локальные пути.
+//
+// Путь строится детерминированно из url (buildSavePath), а индекс url -> путь решает то, что только из url решить нельзя:
+// конфликты файл/директория (/a - файл, /a/b - требует директорию a) и совпадения путей разных url.
+// Индекс сохраняется между запусками, чтобы ссылки в уже сохраненных страницах оставались корректными.
+type PathMapper struct {
+	mu sync.Mutex
+
+	paths      map[string]string
+	dirAliases map[string]string
+
+	// lowercase: case-insensitive filesystems
+	files map[string]string
+	dirs  map[string]struct{}
+}
+
+type pathIndex struct {
+	Paths      map[string]string `json:"paths"`
+	DirAliases map[string]string `json:"dir_aliases"`
+}
+
+func NewPathMapper() *PathMapper {
+	return &PathMapper{
+		paths:      map[string]string{},
+		dirAliases: map[string]string{},
+		files:      map[string]string{},
+		dirs:       map[string]struct{}{},
+	}
+}
+
+// LoadPathMapper загружает индекс из файла, отсутствующий файл - пустой индекс.
+func LoadPathMapper(indexFile string) (*PathMapper, error) {
+	m := NewPathMapper()
+
+	data, err := os.ReadFile(indexFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read path index: %w", err)
+	}
+
+	var index pathIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parse path index %q: %w", indexFile, err)
+	}
+
+	for key, path := range index.Paths {
+		m.paths[key] = path
+		m.register(key, path)
+	}
+	for dir, alias := range index.DirAliases {
+		m.dirAliases[dir] = alias
+	}
+
+	return m, nil
+}
+
+// Save пишет индекс через временный файл, чтобы прерванная запись не испортила индекс.
+func (m *PathMapper) Save(indexFile string) error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(pathIndex{Paths: m.paths, DirAliases: m.dirAliases}, "", "  ")
+	m.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("marshal path index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(indexFile), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	tmpFile := indexFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("write path index: %w", err)
+	}
+
+	return os.Rename(tmpFile, indexFile)
+}
+
+// Map возвращает путь для url, см. buildSavePath. Повторный вызов для того же url возвращает тот же путь.
+func (m *PathMapper) Map(url *urllib.URL, fallbackName string, ext string) string {
+	key := pathKey(url, ext)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if path, ok := m.paths[key]; ok {
+		return path
+	}
+
+	path := m.resolveConflicts(key, buildSavePath(url, fallbackName, ext))
+	m.paths[key] = path
+	m.register(key, path)
+
+	return path
+}
+
+func (m *PathMapper) resolveConflicts(key string, path string) string {
+	dir, file := pathlib.Split(path)
+
+	// directories which are already used as files get an alias, all paths below them use it
+	resolved := ""
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+
+		next := resolved + "/" + segment
+		if alias, ok := m.dirAliases[strings.ToLower(next)]; ok {
+			next = alias
+		} else if _, isFile := m.files[strings.ToLower(next)]; isFile {
+			alias := next + "~" + shortHash(next)
+			m.dirAliases[strings.ToLower(next)] = alias
+			next = alias
+		}
+
+		resolved = next
+	}
+
+	path = resolved + "/" + file
+
+	// file which is already used as a directory or by another url
+	_, isDir := m.dirs[strings.ToLower(path)]
+	owner, isFile := m.files[strings.ToLower(path)]
+	if isDir || (isFile && owner != key) {
+		path = insertBeforeExt(path, "-"+shortHash(key))
+	}
+
+	return path
+}
+
+func (m *PathMapper) register(key string, path string) {
+	m.files[strings.ToLower(path)] = key
+
+	for dir := pathlib.Dir(path); dir != "/" && dir != "."; dir = pathlib.Dir(dir) {
+		m.dirs[strings.ToLower(dir)] = struct{}{}
+	}
+}
+
+func pathKey(url *urllib.URL, ext string) string {
+	u := *url
+	u.Fragment = ""
+	return ext + " " + u.String()
+}
+
+// buildSavePath строит путь только из url:
+//   - query string кодируется хешем в имени файла ("/list?page=2" -> "/list-q1a2b3c4d.html");
+//   - ext добавляется, если у имени его еще нет ("/about" -> "/about.html");
+//   - зарезервированные символы и имена заменяются, длинные сегменты обрезаются с хешем.
+func buildSavePath(url *urllib.URL, fallbackName string, ext string) string {
+	segments := strings.Split(url.Path, "/")
+
+	name := segments[len(segments)-1]
+	if name == "" {
+		name = fallbackName
+	}
+	if name == "" {
+		name = hasher(url.String())
+	}
+
+	if url.RawQuery != "" {
+		name = insertBeforeExt(name, "-q"+shortHash(url.RawQuery))
+	}
+
+	if ext != "" && !hasExt(name, ext) {
+		name += "." + ext
+	}
+
+	var res []string
+	for _, segment := range append(segments[:len(segments)-1], name) {
+		if segment == "" {
+			continue
+		}
+
+		segment = sanitizeSegment(segment)
+		if len(res) == 0 && strings.EqualFold(segment, StateDirName) {
+			segment = "_" + segment
+		}
+
+		res = append(res, truncateSegment(segment))
+	}
+
+	return "/" + strings.Join(res, "/")
+}
+
+func hasExt(name string, ext string) bool {
+	nameExt := strings.ToLower(strings.TrimPrefix(pathlib.Ext(name), "."))
+	if ext == "html" && nameExt == "htm" {
+		return true
+	}
+	return nameExt == strings.ToLower(ext)
+}
+
+func sanitizeSegment(segment string) string {
+	segment = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, segment)
+
+	if segment == "." || segment == ".." {
+		return "_" + segment
+	}
+
+	// windows strips trailing dots and spaces
+	if strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") {
+		segment += "_"
+	}
+
+	base, _, _ := strings.Cut(segment, ".")
+	if _, ok := windowsReservedNames[strings.ToUpper(base)]; ok {
+		segment = "_" + segment
+	}
+
+	return segment
+}
+
+func truncateSegment(segment string) string {
+	if len(segment) <= maxSegmentLen {
+		return segment
+	}
+
+	ext := pathlib.Ext(segment)
+	if len(ext) > 16 {
+		ext = ""
+	}
+
+	suffix := "-" + shortHash(segment) + ext
+	prefix := segment[:maxSegmentLen-len(suffix)]
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+
+	return prefix + suffix
+}
+
+func insertBeforeExt(name string, suffix string) string {
+	ext := pathlib.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	return strings.TrimSuffix(name, ext) + suffix + ext
+}
+
+func shortHash(s string) string {
+	return hasher(s)[:shortHashLen]
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	urllib "net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildSavePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		fallback string
+		ext      string
+		want     string
+	}{
+		{"root", "https://example.com/", "index", "html", "/index.html"},
+		{"dir", "https://example.com/docs/", "index", "html", "/docs/index.html"},
+		{"page", "https://example.com/docs/intro", "index", "html", "/docs/intro.html"},
+		{"html_page", "https://example.com/docs/intro.html", "index", "html", "/docs/intro.html"},
+		{"htm_page", "https://example.com/intro.HTM", "index", "html", "/intro.HTM"},
+		{"asset", "https://example.com/css/main.css", "", "", "/css/main.css"},
+		{"query", "https://example.com/list?page=1", "index", "html", "/list-q" + shortHash("page=1") + ".html"},
+		{"asset_query", "https://example.com/app.js?v=2", "", "", "/app-q" + shortHash("v=2") + ".js"},
+		{"reserved_chars", "https://example.com/a%3Cb%3E:c", "", "", "/a_b__c"},
+		{"dot_segments", "https://example.com/a/%2e%2e/b.css", "", "", "/a/_../b.css"},
+		{"windows_names", "https://example.com/con/aux.txt", "", "", "/_con/_aux.txt"},
+		{"state_dir", "https://example.com/.crawler/paths.json", "", "", "/_.crawler/paths.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := urllib.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buildSavePath(u, tt.fallback, tt.ext); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("long_segment", func(t *testing.T) {
+		u, _ := urllib.Parse("https://example.com/" + strings.Repeat("я", 200) + ".css")
+
+		got := filepath.Base(buildSavePath(u, "", ""))
+		if len(got) > maxSegmentLen || !strings.HasSuffix(got, ".css") {
+			t.Errorf("got %q (%d bytes), want truncated name with extension", got, len(got))
+		}
+	})
+}
+
+func TestPathMapper(t *testing.T) {
+	mustParse := func(rawURL string) *urllib.URL {
+		u, err := urllib.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	t.Run("queries_do_not_collide", func(t *testing.T) {
+		m := NewPathMapper()
+
+		p1 := m.Map(mustParse("https://example.com/list?page=1"), "index", "html")
+		p2 := m.Map(mustParse("https://example.com/list?page=2"), "index", "html")
+		if p1 == p2 {
+			t.Errorf("want different paths, got %q", p1)
+		}
+	})
+
+	t.Run("file_then_dir", func(t *testing.T) {
+		m := NewPathMapper()
+
+		file := m.Map(mustParse("https://example.com/api/data"), "", "")
+		child := m.Map(mustParse("https://example.com/api/data/1"), "", "")
+
+		if strings.HasPrefix(child, file+"/") {
+			t.Errorf("%q is used as a file and as a directory by %q", file, child)
+		}
+	})
+
+	t.Run("dir_then_file", func(t *testing.T) {
+		m := NewPathMapper()
+
+		child := m.Map(mustParse("https://example.com/api/data/1"), "", "")
+		file := m.Map(mustParse("https://example.com/api/data"), "", "")
+
+		if strings.HasPrefix(child, file+"/") {
+			t.Errorf("%q is used as a file and as a directory by %q", file, child)
+		}
+	})
+
+	t.Run("case_insensitive_collision", func(t *testing.T) {
+		m := NewPathMapper()
+
+		p1 := m.Map(mustParse("https://example.com/About"), "index", "html")
+		p2 := m.Map(mustParse("https://example.com/about"), "index", "html")
+		if strings.EqualFold(p1, p2) {
+			t.Errorf("want different paths, got %q and %q", p1, p2)
+		}
+	})
+
+	t.Run("persisted", func(t *testing.T) {
+		indexFile := filepath.Join(t.TempDir(), StateDirName, "paths.json")
+
+		m := NewPathMapper()
+		m.Map(mustParse("https://example.com/a"), "", "")
+		want := m.Map(mustParse("https://example.com/a/b"), "", "")
+
+		if err := m.Save(indexFile); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+
+		loaded, err := LoadPathMapper(indexFile)
+		if err != nil {
+			t.Fatalf("failed to load: %v", err)
+		}
+
+		// the same url in another order must get the same path
+		if got := loaded.Map(mustParse("https://example.com/a/b"), "", ""); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		if got := loaded.Map(mustParse("https://example.com/a/c"), "", ""); !strings.HasPrefix(got, filepath.Dir(want)+"/") {
+			t.Errorf("got %q, want it in the same directory as %q", got, want)
+		}
+	})
+}
+
+func TestMakeRelativeURL(t *testing.T) {
+	if got := makeRelativeURL("/docs/index.html", "/files/a b#1.pdf"); got != "../files/a%20b%231.pdf" {
+		t.Errorf("got %q", got)
+	}
+}
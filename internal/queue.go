@@ -18,6 +18,8 @@ type Queue struct {
 	pagesLimit       int
 	totalQueuedPages int
 	pendingAckCount  int
+	// truncated - хотя бы одна страница не попала в очередь из-за pagesLimit.
+	truncated bool
 }
 
 func NewQueue(ctx context.Context, pagesLimit int, chanSize int, logger *slog.Logger) *Queue {
@@ -100,6 +102,7 @@ func (q *Queue) Push(item Queueable) bool {
 
 	if _, ok := item.(*Page); ok {
 		if q.totalQueuedPages >= q.pagesLimit {
+			q.truncated = true
 			return false
 		}
 		q.totalQueuedPages++
@@ -113,6 +116,14 @@ func (q *Queue) Push(item Queueable) bool {
 	return true
 }
 
+// Truncated - обход остановлен лимитом страниц, а не обошел все, до чего смог дойти.
+func (q *Queue) Truncated() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.truncated
+}
+
 func (q *Queue) Ack(item Queueable) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
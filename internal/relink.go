@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/htmlparser"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"golang.org/x/net/html"
+	urllib "net/url"
+	pathlib "path"
+	"slices"
+	"sync"
+)
+
+// Relinker переписывает после обхода ссылки страниц на элементы, сохраненные не по тому пути, который угадан
+// по url до загрузки (linkSavePath): "/download" оказался pdf, "/file.pdf" - html-страницей.
+// Страница с такой ссылкой может быть сохранена раньше, чем загружен элемент, поэтому это делается, когда сохранено все.
+type Relinker struct {
+	mu sync.Mutex
+
+	// link path -> saved path
+	targets map[string]string
+	// link path -> pages which refer to it
+	parents map[string][]string
+}
+
+func NewRelinker() *Relinker {
+	return &Relinker{
+		targets: map[string]string{},
+		parents: map[string][]string{},
+	}
+}
+
+// Add учитывает сохраненный элемент: страницу - как источник ссылок, любой элемент - как их цель.
+func (r *Relinker) Add(item Queueable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch v := item.(type) {
+	case *Page:
+		if v.SkippedOn != "" {
+			return
+		}
+		mirror := v.mirrorOptions()
+		r.addTarget(mirror.linkSavePath(v.URL), v.ResolveRelativeSavePath())
+
+		// a not modified page keeps the copy saved by the previous crawl, a duplicate is a stub without links
+		if v.IsNotModified() || v.DuplicateOf != nil {
+			return
+		}
+		pagePath := v.ResolveRelativeSavePath()
+		for _, link := range v.Links {
+			linkPath := mirror.linkSavePath(link.URL)
+			if !slices.Contains(r.parents[linkPath], pagePath) {
+				r.parents[linkPath] = append(r.parents[linkPath], pagePath)
+			}
+		}
+	case *asset:
+		if v.SkippedOn == "" && v.linkPath != "" {
+			r.addTarget(v.linkPath, v.ResolveRelativeSavePath())
+		}
+	}
+}
+
+func (r *Relinker) addTarget(linkPath, savePath string) {
+	if linkPath != savePath {
+		r.targets[linkPath] = savePath
+	}
+}
+
+// Pages возвращает страницы, ссылки которых нужно переписать (RelinkPage).
+func (r *Relinker) Pages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res []string
+	for linkPath := range r.targets {
+		for _, pagePath := range r.parents[linkPath] {
+			if !slices.Contains(res, pagePath) {
+				res = append(res, pagePath)
+			}
+		}
+	}
+	slices.Sort(res)
+
+	return res
+}
+
+// RelinkPage переписывает ссылки уже сохраненной страницы на фактические пути элементов.
+func (r *Relinker) RelinkPage(output *safefs.Root, pagePath string) error {
+	content, err := output.ReadFile(pagePath)
+	if err != nil {
+		return fmt.Errorf("read page: %w", err)
+	}
+
+	rootNode, resources, err := htmlparser.ParseHTMLResources(content)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	changed := false
+	for _, res := range resources {
+		// the same resources as the page links, see resolveLinksAndAssets
+		if res.Tag() != "a" && res.Redirect == "" {
+			continue
+		}
+
+		// only rewritten (local) links, see Page.Transform
+		srcURL, err := urllib.Parse(res.SourceURL)
+		if err != nil || srcURL.IsAbs() || srcURL.Host != "" || pathlib.IsAbs(srcURL.Path) {
+			continue
+		}
+
+		// path.Join resolves "../" and never goes above the root
+		target, ok := r.targets[pathlib.Join("/", pathlib.Dir(pagePath), srcURL.Path)]
+		if ok && htmlparser.WriteResourceURL(res.Node, makeRelativeURL(pagePath, target)) {
+			changed = true
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, rootNode); err != nil {
+		return fmt.Errorf("failed to render page content: %v", err)
+	}
+
+	if err := output.WriteFile(pagePath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"strings"
+	"testing"
+)
+
+func TestRelinker(t *testing.T) {
+	output, err := safefs.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	parent, _ := NewPage("https://example.com/docs/index.html")
+	_ = parent.SetContent([]byte(`<a href="/files/file.pdf#page=2">html behind pdf</a><a href="../download?id=5">pdf without extension</a><a href="/about">about</a>`))
+	if err := parent.Parse(); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if err := parent.Transform(); err != nil {
+		t.Fatalf("failed to transform: %v", err)
+	}
+	parentPath := parent.ResolveRelativeSavePath()
+	if err := output.WriteFile(parentPath, parent.GetContent(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	htmlPage, _ := NewPage("https://example.com/files/file.pdf")
+	_ = htmlPage.SetContent([]byte("<!DOCTYPE html><html></html>"))
+	htmlPage.SetContentType("text/html")
+	htmlItem := htmlPage.Classify()
+
+	pdfPage, _ := NewPage("https://example.com/download?id=5")
+	_ = pdfPage.SetContent([]byte("%PDF-1.4"))
+	pdfPage.SetContentType("application/pdf")
+	pdfItem := pdfPage.Classify()
+
+	aboutPage, _ := NewPage("https://example.com/about")
+
+	relinker := NewRelinker()
+	// the parent is saved before the items it refers to
+	for _, item := range []Queueable{parent, htmlItem, pdfItem, aboutPage} {
+		relinker.Add(item)
+	}
+
+	pages := relinker.Pages()
+	if len(pages) != 1 || pages[0] != parentPath {
+		t.Fatalf("got pages %v, want [%s]", pages, parentPath)
+	}
+	if err := relinker.RelinkPage(output, parentPath); err != nil {
+		t.Fatalf("failed to relink: %v", err)
+	}
+
+	content, _ := output.ReadFile(parentPath)
+
+	pdfPath := pdfItem.(Savable).ResolveRelativeSavePath()
+	want := []string{
+		`href="../files/file.pdf.html"`,
+		`href="..` + pdfPath + `"`,
+		`href="../about.html"`,
+	}
+	for _, w := range want {
+		if !strings.Contains(string(content), w) {
+			t.Errorf("%s not found in %s", w, content)
+		}
+	}
+	if !strings.HasSuffix(pdfPath, ".pdf") {
+		t.Errorf("got pdf path %q", pdfPath)
+	}
+}
@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"github.com/gallyamow/go-crawler/pkg/safefs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Report collects notable crawl events, which are not errors but are worth to review after the crawl.
+type Report struct {
+	mu sync.Mutex
+
+	Redirects           []RedirectRecord  `json:"redirects"`
+	IntegrityMismatches []ItemRecord      `json:"integrity_mismatches"`
+	Rejections          []RejectionRecord `json:"rejections"`
+	UnsafePaths         []ItemRecord      `json:"unsafe_paths"`
+	BlockedAddresses    []ItemRecord      `json:"blocked_addresses"`
+	LimitViolations     []ItemRecord      `json:"limit_violations"`
+	TLS                 []TLSRecord       `json:"tls"`
+	PageAliases         []PageAliasRecord `json:"page_aliases"`
+	// NearDuplicates - nil, если поиск почти одинаковых страниц выключен.
+	NearDuplicates []NearDuplicateCluster `json:"near_duplicates,omitempty"`
+	// Sync - nil, если sync не выполнялся.
+	Sync *SyncRecord `json:"sync,omitempty"`
+	// Dedup - nil, если dedup выключен.
+	Dedup *DedupRecord `json:"dedup,omitempty"`
+}
+
+type RejectionRecord struct {
+	URL    string `json:"url"`
+	Rule   string `json:"rule"`
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+}
+
+// PageAliasRecord - страница, сохраненная заглушкой на Primary, Reason - PageAliasContent или PageAliasCanonical.
+type PageAliasRecord struct {
+	URL     string `json:"url"`
+	Primary string `json:"primary"`
+	Reason  string `json:"reason"`
+}
+
+// NearDuplicateCluster - страницы, почти совпадающие по тексту с Primary (см. NearDedup).
+type NearDuplicateCluster struct {
+	Primary string                `json:"primary"`
+	Pages   []NearDuplicateRecord `json:"pages"`
+}
+
+// NearDuplicateRecord - Distance - расстояние Хэмминга между отпечатками страницы и Primary.
+type NearDuplicateRecord struct {
+	URL      string `json:"url"`
+	Distance int    `json:"distance"`
+}
+
+type RedirectRecord struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// TLSRecord - параметры TLS-соединения, по которому получен ответ.
+type TLSRecord struct {
+	URL         string `json:"url"`
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	// ExpiresAt - когда истекает первый из сертификатов цепочки.
+	ExpiresAt time.Time           `json:"expires_at"`
+	Chain     []CertificateRecord `json:"chain"`
+}
+
+type CertificateRecord struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// SyncRecord - результат MirrorSync.Sync.
+type SyncRecord struct {
+	Mode   SyncMode `json:"mode"`
+	DryRun bool     `json:"dry_run"`
+	// Stale - устаревшие файлы, Removed - те из них, что удалены или перенесены в карантин.
+	Stale   []string `json:"stale"`
+	Removed []string `json:"removed"`
+	// URLs - url, все файлы которых убраны из копии.
+	URLs []string `json:"urls"`
+	// Refused - почему ничего не удалено, хотя устаревшие файлы есть.
+	Refused string       `json:"refused,omitempty"`
+	Errors  []ItemRecord `json:"errors,omitempty"`
+}
+
+// DedupRecord - DedupStats обхода, Ratio - размер файлов к размеру blobs.
+type DedupRecord struct {
+	Mode        DedupMode `json:"mode"`
+	Files       int       `json:"files"`
+	Blobs       int       `json:"blobs"`
+	Bytes       int64     `json:"bytes"`
+	StoredBytes int64     `json:"stored_bytes"`
+	Ratio       float64   `json:"ratio"`
+}
+
+// ItemRecord - событие, относящееся к одному элементу.
+type ItemRecord struct {
+	URL     string `json:"url"`
+	Message string `json:"message"`
+}
+
+func NewReport() *Report {
+	return &Report{}
+}
+
+func (r *Report) RecordRedirect(from, to, kind string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Redirects = append(r.Redirects, RedirectRecord{From: from, To: to, Kind: kind})
+}
+
+func (r *Report) RecordIntegrityMismatch(url string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.IntegrityMismatches = append(r.IntegrityMismatches, ItemRecord{URL: url, Message: err.Error()})
+}
+
+func (r *Report) RecordRejection(url string, rejection *FilterRejection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Rejections = append(r.Rejections, RejectionRecord{URL: url, Rule: rejection.Rule, Stage: rejection.Stage, Reason: rejection.Reason})
+}
+
+func (r *Report) RecordUnsafePath(url string, err *safefs.PathError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.UnsafePaths = append(r.UnsafePaths, ItemRecord{URL: url, Message: err.Error()})
+}
+
+func (r *Report) RecordBlockedAddress(url string, err *httpclient.BlockedAddressError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.BlockedAddresses = append(r.BlockedAddresses, ItemRecord{URL: url, Message: err.Error()})
+}
+
+func (r *Report) RecordLimitViolation(url string, err *httpclient.LimitError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.LimitViolations = append(r.LimitViolations, ItemRecord{URL: url, Message: err.Error()})
+}
+
+func (r *Report) RecordPageAlias(url, primary, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.PageAliases = append(r.PageAliases, PageAliasRecord{URL: url, Primary: primary, Reason: reason})
+}
+
+func (r *Report) RecordNearDuplicates(clusters []NearDuplicateCluster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.NearDuplicates = clusters
+}
+
+func (r *Report) RecordSync(record SyncRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Sync = &record
+}
+
+func (r *Report) RecordDedup(mode DedupMode, stats DedupStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Dedup = &DedupRecord{Mode: mode, Files: stats.Files, Blobs: stats.Blobs, Bytes: stats.Bytes, StoredBytes: stats.StoredBytes, Ratio: stats.Ratio()}
+}
+
+func (r *Report) RecordTLS(url string, info *httpclient.TLSInfo) {
+	record := TLSRecord{URL: url, Version: info.Version, CipherSuite: info.CipherSuite, ExpiresAt: info.ExpiresAt()}
+	for _, cert := range info.Chain {
+		record.Chain = append(record.Chain, CertificateRecord{Subject: cert.Subject, Issuer: cert.Issuer, NotAfter: cert.NotAfter})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.TLS = append(r.TLS, record)
+}
+
+// Save пишет отчет в json-файл.
+func (r *Report) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	return nil
+}
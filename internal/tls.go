@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/httpclient"
+	"strings"
+)
+
+// TLSRule - настройки TLS хоста из config-файла.
+type TLSRule struct {
+	// Host - "wiki.example.com" или "*.example.com", см. httpclient.TLSHost.
+	Host string `json:"host"`
+	// ClientCert, ClientKey - PEM-файлы сертификата и ключа для mTLS.
+	ClientCert         string   `json:"client_cert"`
+	ClientKey          string   `json:"client_key"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify"`
+	Pins               []string `json:"pins"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func parseTLSVersion(value string) (uint16, error) {
+	version, ok := tlsVersions[value]
+	if !ok {
+		return 0, fmt.Errorf("tls-min-version must be one of 1.0, 1.1, 1.2, 1.3, got %q", value)
+	}
+	return version, nil
+}
+
+func (r TLSRule) resolve() (httpclient.TLSHost, error) {
+	if r.Host == "" {
+		return httpclient.TLSHost{}, fmt.Errorf("tls rule: host cannot be empty")
+	}
+	if strings.Contains(r.Host, ":") {
+		return httpclient.TLSHost{}, fmt.Errorf("tls rule %s: host cannot have a port", r.Host)
+	}
+
+	host := httpclient.TLSHost{Host: r.Host, InsecureSkipVerify: r.InsecureSkipVerify, Pins: r.Pins}
+
+	if r.ClientCert != "" || r.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(r.ClientCert, r.ClientKey)
+		if err != nil {
+			return httpclient.TLSHost{}, fmt.Errorf("tls rule %s: client certificate: %w", r.Host, err)
+		}
+		host.ClientCert = &cert
+	}
+
+	for _, pin := range r.Pins {
+		if err := validatePin(pin); err != nil {
+			return httpclient.TLSHost{}, fmt.Errorf("tls rule %s: %w", r.Host, err)
+		}
+	}
+
+	return host, nil
+}
+
+// validatePin проверяет формат httpclient.SPKIPin: "sha256/<base64>".
+func validatePin(pin string) error {
+	encoded, ok := strings.CutPrefix(pin, "sha256/")
+	if !ok {
+		return fmt.Errorf("pin %q must start with sha256/", pin)
+	}
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("pin %q is not a base64 sha256 hash", pin)
+	}
+	return nil
+}
+
+// resolveTLS загружает клиентские сертификаты при загрузке конфигурации, чтобы ошибки в них были видны до начала обхода.
+func resolveTLS(rules []TLSRule) ([]httpclient.TLSHost, error) {
+	var res []httpclient.TLSHost
+	for _, rule := range rules {
+		host, err := rule.resolve()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, host)
+	}
+	return res, nil
+}
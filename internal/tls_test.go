@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigLoadFileTLS(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{"tls": [
+		{"host": "dev.internal", "insecure_skip_verify": true},
+		{"host": "*.example.com", "pins": ["sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="]}
+	]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{TLSMinVersion: "1.3"}
+	if err := config.loadFile(configFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tlsConfig.Hosts) != 2 || !tlsConfig.Hosts[0].InsecureSkipVerify || len(tlsConfig.Hosts[1].Pins) != 1 {
+		t.Errorf("unexpected hosts: %+v", tlsConfig.Hosts)
+	}
+}
+
+func TestConfigTLSErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		rule   string
+	}{
+		{"unknown_version", Config{TLSMinVersion: "1.4"}, ""},
+		{"missing_ca", Config{TLSMinVersion: "1.2", CACerts: []string{"/nonexistent/ca.pem"}}, ""},
+		{"host_with_port", Config{TLSMinVersion: "1.2"}, `{"host": "a.com:8443", "insecure_skip_verify": true}`},
+		{"invalid_pin", Config{TLSMinVersion: "1.2"}, `{"host": "a.com", "pins": ["md5/abc"]}`},
+		{"missing_key", Config{TLSMinVersion: "1.2"}, `{"host": "a.com", "client_cert": "/nonexistent/client.pem"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if tt.rule != "" {
+				configFile := filepath.Join(t.TempDir(), "config.json")
+				if err := os.WriteFile(configFile, []byte(`{"tls": [`+tt.rule+`]}`), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := config.loadFile(configFile); err != nil {
+					return
+				}
+			}
+
+			if _, err := config.TLSConfig(); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+}
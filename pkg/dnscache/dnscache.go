@@ -0,0 +1,254 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DialFunc - сигнатура net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// LookupFunc - сигнатура net.Resolver.LookupNetIP.
+type LookupFunc func(ctx context.Context, network, host string) ([]netip.Addr, error)
+
+// Resolver кэширует результаты DNS на ttl (системный resolver TTL записей не сообщает) и подменяет адреса
+// хостов из overrides, как curl --resolve. Конкурентные запросы одного хоста ждут один lookup.
+// Ошибки не кэшируются.
+type Resolver struct {
+	ttl       time.Duration
+	lookup    LookupFunc
+	overrides map[string][]netip.Addr
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+
+	hits       atomic.Int64
+	misses     atomic.Int64
+	errors     atomic.Int64
+	overridden atomic.Int64
+}
+
+type entry struct {
+	// ready закрывается, когда lookup завершен
+	ready     chan struct{}
+	addrs     []netip.Addr
+	err       error
+	expiresAt time.Time
+}
+
+// Stats - счетчики Resolver.
+type Stats struct {
+	Hits   int64
+	Misses int64
+	Errors int64
+	// Overrides - сколько раз адрес взят из overrides.
+	Overrides int64
+	Entries   int
+}
+
+type Option func(*Resolver)
+
+// New создает resolver, ttl 0 отключает кэш (overrides при этом работают).
+func New(ttl time.Duration, options ...Option) *Resolver {
+	r := &Resolver{
+		ttl:       ttl,
+		lookup:    net.DefaultResolver.LookupNetIP,
+		overrides: map[string][]netip.Addr{},
+		now:       time.Now,
+		entries:   map[string]*entry{},
+	}
+
+	for _, opt := range options {
+		opt(r)
+	}
+
+	return r
+}
+
+// WithOverrides задает адреса для "host:port", см. ParseOverride.
+func WithOverrides(overrides map[string][]netip.Addr) Option {
+	return func(r *Resolver) {
+		for hostPort, addrs := range overrides {
+			r.overrides[strings.ToLower(hostPort)] = addrs
+		}
+	}
+}
+
+func WithLookupFunc(lookup LookupFunc) Option {
+	return func(r *Resolver) {
+		r.lookup = lookup
+	}
+}
+
+// ParseOverride разбирает "host:port:addr[,addr...]" (формат curl --resolve), адреса - только ip.
+// IPv6-хост пишется в скобках: "[2001:db8::1]:443:addr", адреса - как в скобках, так и без них.
+func ParseOverride(value string) (string, []netip.Addr, error) {
+	invalid := fmt.Errorf("invalid override %q, want host:port:addr", value)
+
+	// the host can't contain ':' unless it's bracketed, the addresses can
+	var host, rest string
+	if bracketed, ok := strings.CutPrefix(value, "["); ok {
+		var found bool
+		host, rest, found = strings.Cut(bracketed, "]:")
+		if !found || !strings.Contains(host, ":") {
+			return "", nil, invalid
+		}
+	} else {
+		var found bool
+		host, rest, found = strings.Cut(value, ":")
+		if !found {
+			return "", nil, invalid
+		}
+	}
+
+	port, list, found := strings.Cut(rest, ":")
+	if !found || host == "" {
+		return "", nil, invalid
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return "", nil, fmt.Errorf("invalid port in override %q", value)
+	}
+
+	var addrs []netip.Addr
+	for _, item := range strings.Split(list, ",") {
+		addr, err := netip.ParseAddr(strings.Trim(strings.TrimSpace(item), "[]"))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid address %q in override %q", item, value)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	return net.JoinHostPort(host, port), addrs, nil
+}
+
+// Lookup возвращает адреса хоста из кэша или из DNS.
+func (r *Resolver) Lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	host = strings.ToLower(host)
+	if r.ttl <= 0 {
+		return r.resolve(ctx, host)
+	}
+
+	r.mu.Lock()
+	e, ok := r.entries[host]
+	if ok && (e.expiresAt.IsZero() || r.now().Before(e.expiresAt)) {
+		r.mu.Unlock()
+		r.hits.Add(1)
+
+		select {
+		case <-e.ready:
+			return e.addrs, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	e = &entry{ready: make(chan struct{})}
+	r.entries[host] = e
+	r.mu.Unlock()
+
+	// the lookup is not bound to the first caller: the others wait for it too
+	e.addrs, e.err = r.resolve(context.WithoutCancel(ctx), host)
+
+	r.mu.Lock()
+	if e.err != nil {
+		delete(r.entries, host)
+	} else {
+		e.expiresAt = r.now().Add(r.ttl)
+	}
+	r.mu.Unlock()
+	close(e.ready)
+
+	return e.addrs, e.err
+}
+
+func (r *Resolver) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	r.misses.Add(1)
+
+	addrs, err := r.lookup(ctx, "ip", host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		r.errors.Add(1)
+		return nil, err
+	}
+
+	for i := range addrs {
+		addrs[i] = addrs[i].Unmap()
+	}
+	return addrs, nil
+}
+
+// LookupAddress возвращает адреса для "host:port": ip - как есть, затем overrides, затем Lookup.
+func (r *Resolver) LookupAddress(ctx context.Context, address string) ([]netip.Addr, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr.Unmap()}, nil
+	}
+
+	if addrs, ok := r.overrides[strings.ToLower(address)]; ok {
+		r.overridden.Add(1)
+		return addrs, nil
+	}
+	return r.Lookup(ctx, host)
+}
+
+// DialContext оборачивает dial: хост заменяется адресами из overrides или Lookup, которые пробуются по очереди.
+// Сам dial получает уже ip, поэтому DialGuard по-прежнему проверяет каждый адрес.
+func (r *Resolver) DialContext(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := netip.ParseAddr(host); err == nil {
+			return dial(ctx, network, address)
+		}
+
+		addrs, err := r.LookupAddress(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+
+		var errs []error
+		for _, addr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+func (r *Resolver) Stats() Stats {
+	r.mu.Lock()
+	entries := len(r.entries)
+	r.mu.Unlock()
+
+	return Stats{
+		Hits:      r.hits.Load(),
+		Misses:    r.misses.Load(),
+		Errors:    r.errors.Load(),
+		Overrides: r.overridden.Load(),
+		Entries:   entries,
+	}
+}
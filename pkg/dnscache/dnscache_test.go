@@ -0,0 +1,167 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeDNS struct {
+	calls atomic.Int32
+	err   error
+	delay time.Duration
+}
+
+func (f *fakeDNS) lookup(_ context.Context, _, host string) ([]netip.Addr, error) {
+	f.calls.Add(1)
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []netip.Addr{netip.MustParseAddr("::ffff:192.0.2.1")}, nil
+}
+
+func TestResolverCache(t *testing.T) {
+	dns := &fakeDNS{}
+	now := time.Now()
+	r := New(time.Minute, WithLookupFunc(dns.lookup))
+	r.now = func() time.Time { return now }
+
+	for range 3 {
+		addrs, err := r.Lookup(t.Context(), "Example.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.1") {
+			t.Fatalf("got %v, want unmapped 192.0.2.1", addrs)
+		}
+	}
+	if _, err := r.Lookup(t.Context(), "example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := dns.calls.Load(); got != 1 {
+		t.Errorf("got %d lookups, want 1", got)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := r.Lookup(t.Context(), "example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := dns.calls.Load(); got != 2 {
+		t.Errorf("got %d lookups after ttl, want 2", got)
+	}
+
+	want := Stats{Hits: 3, Misses: 2, Entries: 1}
+	if got := r.Stats(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestResolverConcurrentLookup(t *testing.T) {
+	dns := &fakeDNS{delay: 50 * time.Millisecond}
+	r := New(time.Minute, WithLookupFunc(dns.lookup))
+
+	var wg sync.WaitGroup
+	wg.Add(10)
+	for range 10 {
+		go func() {
+			defer wg.Done()
+			if _, err := r.Lookup(context.Background(), "example.com"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := dns.calls.Load(); got != 1 {
+		t.Errorf("got %d lookups, want 1", got)
+	}
+}
+
+func TestResolverErrorsAreNotCached(t *testing.T) {
+	dns := &fakeDNS{err: errors.New("server misbehaving")}
+	r := New(time.Minute, WithLookupFunc(dns.lookup))
+
+	for range 2 {
+		if _, err := r.Lookup(t.Context(), "example.com"); err == nil {
+			t.Fatal("want error")
+		}
+	}
+
+	if got := dns.calls.Load(); got != 2 {
+		t.Errorf("got %d lookups, want 2", got)
+	}
+	if got := r.Stats(); got.Errors != 2 || got.Entries != 0 {
+		t.Errorf("unexpected stats %+v", got)
+	}
+}
+
+func TestParseOverride(t *testing.T) {
+	tests := []struct {
+		value    string
+		hostPort string
+		addrs    string
+		wantErr  bool
+	}{
+		{"example.com:443:127.0.0.1", "example.com:443", "[127.0.0.1]", false},
+		{"example.com:80:10.0.0.1,[::1]", "example.com:80", "[10.0.0.1 ::1]", false},
+		{"example.com:443", "", "", true},
+		{"example.com:https:127.0.0.1", "", "", true},
+		{"example.com:443:localhost", "", "", true},
+		{":443:127.0.0.1", "", "", true},
+		{"example.com:443:2001:db8::1,[2001:db8::2]", "example.com:443", "[2001:db8::1 2001:db8::2]", false},
+		{"[2001:DB8::1]:443:::1", "[2001:DB8::1]:443", "[::1]", false},
+		{"[2001:db8::1]:443", "", "", true},
+		{"[example.com]:443:127.0.0.1", "", "", true},
+		{"2001:db8::1:443:::1", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			hostPort, addrs, err := ParseOverride(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if hostPort != tt.hostPort || fmt.Sprint(addrs) != tt.addrs {
+				t.Errorf("got %s %s, want %s %s", hostPort, fmt.Sprint(addrs), tt.hostPort, tt.addrs)
+			}
+		})
+	}
+}
+
+func TestResolverDialContext(t *testing.T) {
+	dns := &fakeDNS{}
+	r := New(time.Minute,
+		WithLookupFunc(dns.lookup),
+		WithOverrides(map[string][]netip.Addr{"Staging.example.com:443": {netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.3")}}),
+	)
+
+	var dialed []string
+	dial := r.DialContext(func(_ context.Context, _, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return nil, errors.New("refused")
+	})
+
+	_, _ = dial(t.Context(), "tcp", "staging.example.com:443")
+	_, _ = dial(t.Context(), "tcp", "example.com:80")
+	_, _ = dial(t.Context(), "tcp", "192.0.2.7:80")
+
+	want := []string{"10.0.0.2:443", "10.0.0.3:443", "192.0.2.1:80", "192.0.2.7:80"}
+	if !slices.Equal(dialed, want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+	if got := r.Stats(); got.Overrides != 1 || got.Misses != 1 {
+		t.Errorf("unexpected stats %+v", got)
+	}
+}
@@ -0,0 +1,214 @@
+package htmlparser
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/net/html"
+	urllib "net/url"
+	"slices"
+	"strings"
+)
+
+// Form - html-форма с полями, которые браузер отправил бы без изменений (в том числе hidden, например CSRF-токен).
+type Form struct {
+	Action string
+	Method string
+	Fields urllib.Values
+}
+
+// FindForm ищет первую форму, подходящую под selector: "form", "#login", "form#login", "form.auth", "form[name=login]".
+// Пустой selector выбирает первую форму с полем password.
+func FindForm(pageContent []byte, selector string) (*Form, error) {
+	rootNode, err := html.Parse(bytes.NewBuffer(pageContent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	match, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var forms []*html.Node
+	walk(rootNode, func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "form" {
+			forms = append(forms, node)
+		}
+	})
+
+	for _, node := range forms {
+		if selector == "" && !hasPasswordField(node) {
+			continue
+		}
+		if match(node) {
+			return readForm(node), nil
+		}
+	}
+
+	return nil, fmt.Errorf("form %q not found", selector)
+}
+
+func readForm(node *html.Node) *Form {
+	action, _ := readHTMLNodeAttrValue(node, "action")
+	method, _ := readHTMLNodeAttrValue(node, "method")
+	if method == "" {
+		method = "GET"
+	}
+
+	form := &Form{Action: action, Method: strings.ToUpper(method), Fields: urllib.Values{}}
+
+	walk(node, func(field *html.Node) {
+		if field.Type != html.ElementNode {
+			return
+		}
+
+		name, ok := readHTMLNodeAttrValue(field, "name")
+		if !ok || name == "" {
+			return
+		}
+		if _, disabled := readHTMLNodeAttrValue(field, "disabled"); disabled {
+			return
+		}
+
+		switch field.Data {
+		case "input":
+			inputType, _ := readHTMLNodeAttrValue(field, "type")
+			switch strings.ToLower(inputType) {
+			case "submit", "button", "image", "reset", "file":
+				return
+			case "checkbox", "radio":
+				if _, checked := readHTMLNodeAttrValue(field, "checked"); !checked {
+					return
+				}
+			}
+			value, _ := readHTMLNodeAttrValue(field, "value")
+			form.Fields.Add(name, value)
+		case "textarea":
+			form.Fields.Add(name, textContent(field))
+		case "select":
+			form.Fields.Add(name, selectedOption(field))
+		}
+	})
+
+	return form
+}
+
+func hasPasswordField(form *html.Node) bool {
+	found := false
+	walk(form, func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "input" {
+			if inputType, _ := readHTMLNodeAttrValue(node, "type"); strings.EqualFold(inputType, "password") {
+				found = true
+			}
+		}
+	})
+	return found
+}
+
+func selectedOption(node *html.Node) string {
+	var first, selected *html.Node
+	walk(node, func(option *html.Node) {
+		if option.Type != html.ElementNode || option.Data != "option" {
+			return
+		}
+		if first == nil {
+			first = option
+		}
+		if _, ok := readHTMLNodeAttrValue(option, "selected"); ok && selected == nil {
+			selected = option
+		}
+	})
+
+	if selected == nil {
+		selected = first
+	}
+	if selected == nil {
+		return ""
+	}
+	if value, ok := readHTMLNodeAttrValue(selected, "value"); ok {
+		return value
+	}
+	return strings.TrimSpace(textContent(selected))
+}
+
+func textContent(node *html.Node) string {
+	var sb strings.Builder
+	walk(node, func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+	})
+	return sb.String()
+}
+
+// parseSelector поддерживает только простые селекторы одного элемента: tag, #id, .class, [attr=value] и их сочетания.
+func parseSelector(selector string) (func(*html.Node) bool, error) {
+	var checks []func(*html.Node) bool
+
+	rest := strings.TrimSpace(selector)
+	tagEnd := strings.IndexAny(rest, "#.[")
+	if tagEnd < 0 {
+		tagEnd = len(rest)
+	}
+	if tag := rest[:tagEnd]; tag != "" {
+		checks = append(checks, func(n *html.Node) bool { return n.Data == strings.ToLower(tag) })
+	}
+	rest = rest[tagEnd:]
+
+	for rest != "" {
+		switch rest[0] {
+		case '#', '.':
+			end := strings.IndexAny(rest[1:], "#.[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			value := rest[1 : end+1]
+			if value == "" {
+				return nil, fmt.Errorf("invalid selector %q", selector)
+			}
+			if rest[0] == '#' {
+				checks = append(checks, func(n *html.Node) bool {
+					id, _ := readHTMLNodeAttrValue(n, "id")
+					return id == value
+				})
+			} else {
+				checks = append(checks, func(n *html.Node) bool {
+					class, _ := readHTMLNodeAttrValue(n, "class")
+					return slices.Contains(strings.Fields(class), value)
+				})
+			}
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid selector %q", selector)
+			}
+			attr, value, hasValue := strings.Cut(rest[1:end], "=")
+			attr = strings.TrimSpace(attr)
+			value = strings.Trim(strings.TrimSpace(value), `"'`)
+			checks = append(checks, func(n *html.Node) bool {
+				v, ok := readHTMLNodeAttrValue(n, attr)
+				return ok && (!hasValue || v == value)
+			})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unsupported selector %q", selector)
+		}
+	}
+
+	return func(n *html.Node) bool {
+		for _, check := range checks {
+			if !check(n) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func walk(node *html.Node, fn func(*html.Node)) {
+	fn(node)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
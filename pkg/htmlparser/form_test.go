@@ -0,0 +1,65 @@
+package htmlparser
+
+import (
+	"testing"
+)
+
+func TestFindForm(t *testing.T) {
+	page := []byte(`<html><body>
+		<form id="search" action="/search"><input name="q"></form>
+		<form class="auth wide" name="login" action="/session" method="post">
+			<input type="hidden" name="csrf_token" value="abc123">
+			<input type="text" name="username" value="">
+			<input type="password" name="password">
+			<input type="checkbox" name="remember" value="1" checked>
+			<input type="checkbox" name="newsletter" value="1">
+			<input type="text" name="disabled" value="x" disabled>
+			<select name="lang"><option value="en">English</option><option value="de" selected>Deutsch</option></select>
+			<textarea name="note">hi</textarea>
+			<input type="submit" name="go" value="Sign in">
+		</form>
+	</body></html>`)
+
+	tests := []struct {
+		selector   string
+		wantAction string
+		wantErr    bool
+	}{
+		{"", "/session", false},
+		{"form", "/search", false},
+		{"#search", "/search", false},
+		{"form.auth", "/session", false},
+		{"form[name=login]", "/session", false},
+		{"form[name='login'].wide", "/session", false},
+		{"form#missing", "", true},
+		{"form > input", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			form, err := FindForm(page, tt.selector)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if form.Action != tt.wantAction {
+				t.Errorf("got action %q, want %q", form.Action, tt.wantAction)
+			}
+		})
+	}
+
+	form, _ := FindForm(page, "form.auth")
+	if form.Method != "POST" {
+		t.Errorf("got method %q", form.Method)
+	}
+
+	want := "csrf_token=abc123&lang=de&note=hi&password=&remember=1&username="
+	if got := form.Fields.Encode(); got != want {
+		t.Errorf("got fields %q, want %q", got, want)
+	}
+}
@@ -4,18 +4,32 @@ import (
 	"bytes"
 	"fmt"
 	"golang.org/x/net/html"
+	"regexp"
 	"slices"
+	"strings"
+)
+
+const (
+	RedirectMetaRefresh = "meta-refresh"
+	RedirectScript      = "script"
 )
 
 type HTMLResource struct {
 	Node      *html.Node
 	SourceURL string
+	// Redirect is not empty when the resource is a client-side navigation (meta refresh or javascript).
+	Redirect string
 }
 
 func (rn *HTMLResource) Tag() string {
 	return rn.Node.Data
 }
 
+// HasRel проверяет наличие значения в атрибуте rel (список значений через пробел).
+func (rn *HTMLResource) HasRel(rel string) bool {
+	return slices.Contains(readLinkRel(rn.Node), rel)
+}
+
 // ParseHTMLResources парсит html и возвращает данные как есть.
 func ParseHTMLResources(pageContent []byte) (*html.Node, []*HTMLResource, error) {
 	rootNode, err := html.Parse(bytes.NewBuffer(pageContent))
@@ -23,7 +37,15 @@ func ParseHTMLResources(pageContent []byte) (*html.Node, []*HTMLResource, error)
 		return nil, nil, fmt.Errorf("failed to parse html: %w", err)
 	}
 
-	resources := collect(rootNode, []string{"a", "link", "script", "img"}, func(node *html.Node) (*HTMLResource, bool) {
+	resources := collect(rootNode, []string{"a", "link", "script", "img", "meta"}, func(node *html.Node) (*HTMLResource, bool) {
+		if src, kind, ok := ReadRedirectURL(node); ok {
+			return &HTMLResource{
+				Node:      node,
+				SourceURL: src,
+				Redirect:  kind,
+			}, true
+		}
+
 		src, ok := ReadResourceURL(node)
 		if !ok {
 			return nil, false
@@ -49,8 +71,7 @@ func ReadResourceURL(node *html.Node) (string, bool) {
 		src, ok = readHTMLNodeAttrValue(node, "src")
 	case "link":
 		typeAttr, _ := readHTMLNodeAttrValue(node, "type")
-		relAttr, _ := readHTMLNodeAttrValue(node, "rel")
-		if typeAttr == "text/css" || relAttr == "stylesheet" {
+		if typeAttr == "text/css" || slices.ContainsFunc(readLinkRel(node), isAssetLinkRel) {
 			src, ok = readHTMLNodeAttrValue(node, "href")
 		}
 	case "a":
@@ -64,10 +85,125 @@ func ReadResourceURL(node *html.Node) (string, bool) {
 	return src, true
 }
 
+// ReadRedirectURL возвращает цель клиентского редиректа: <meta http-equiv="refresh"> или inline <script> с window.location.
+func ReadRedirectURL(node *html.Node) (string, string, bool) {
+	switch node.Data {
+	case "meta":
+		httpEquiv, _ := readHTMLNodeAttrValue(node, "http-equiv")
+		if !strings.EqualFold(httpEquiv, "refresh") {
+			return "", "", false
+		}
+
+		content, _ := readHTMLNodeAttrValue(node, "content")
+		_, target, ok := parseRefreshContent(content)
+		if !ok {
+			return "", "", false
+		}
+		return target, RedirectMetaRefresh, true
+	case "script":
+		// only inline scripts
+		if _, ok := readHTMLNodeAttrValue(node, "src"); ok {
+			return "", "", false
+		}
+
+		if node.FirstChild == nil || node.FirstChild.Type != html.TextNode {
+			return "", "", false
+		}
+
+		for _, re := range scriptRedirectPatterns {
+			if m := re.FindStringSubmatch(node.FirstChild.Data); m != nil && m[2] != "" {
+				return m[2], RedirectScript, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// FindCanonicalURL возвращает href первого <link rel="canonical"> документа.
+func FindCanonicalURL(rootNode *html.Node) (string, bool) {
+	var href string
+	var found bool
+	walk(rootNode, func(node *html.Node) {
+		if found || node.Type != html.ElementNode || node.Data != "link" || !slices.Contains(readLinkRel(node), "canonical") {
+			return
+		}
+		href, found = readHTMLNodeAttrValue(node, "href")
+	})
+	return href, found
+}
+
+// invisibleTags - содержимое этих элементов не показывается как текст страницы.
+var invisibleTags = []string{"head", "script", "style", "noscript", "template", "svg"}
+
+// VisibleText возвращает текст документа, который видит читатель, фрагменты разделены пробелами.
+func VisibleText(rootNode *html.Node) string {
+	var b strings.Builder
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && slices.Contains(invisibleTags, node.Data) {
+			return
+		}
+		if node.Type == html.TextNode {
+			if text := strings.TrimSpace(node.Data); text != "" {
+				if b.Len() > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(text)
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(rootNode)
+
+	return b.String()
+}
+
+// scriptRedirectPatterns covers the most common forms only, we don't execute javascript:
+// window.location = "...", location.href = '...', location.replace("..."), location.assign("...").
+var scriptRedirectPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\blocation(?:\.href)?\s*=\s*(["'])([^"']*)["']`),
+	regexp.MustCompile(`\blocation\.(?:replace|assign)\(\s*(["'])([^"']*)["']\s*\)`),
+}
+
+// parseRefreshContent разбирает значение вида "5; url=/next.html" (url= может отсутствовать, значение может быть в кавычках).
+func parseRefreshContent(content string) (string, string, bool) {
+	delay, rest, found := strings.Cut(content, ";")
+	if !found {
+		delay, rest, found = strings.Cut(content, ",")
+	}
+
+	delay = strings.TrimSpace(delay)
+	rest = strings.TrimSpace(rest)
+	if !found || rest == "" {
+		return "", "", false
+	}
+
+	if key, value, ok := strings.Cut(rest, "="); ok && strings.EqualFold(strings.TrimSpace(key), "url") {
+		rest = strings.TrimSpace(value)
+	}
+
+	rest = strings.Trim(rest, `"'`)
+	if rest == "" {
+		return "", "", false
+	}
+
+	return delay, rest, true
+}
+
 func WriteResourceURL(node *html.Node, newURL string) bool {
 	tag := node.Data
 
 	switch tag {
+	case "meta":
+		content, _ := readHTMLNodeAttrValue(node, "content")
+		delay, _, ok := parseRefreshContent(content)
+		if !ok {
+			return false
+		}
+		return setHTMLNodeAttrValue(node, "content", delay+";url="+newURL)
 	case "script", "img":
 		return setHTMLNodeAttrValue(node, "src", newURL)
 	case "link":
@@ -79,6 +215,45 @@ func WriteResourceURL(node *html.Node, newURL string) bool {
 	return false
 }
 
+// ReadIntegrity возвращает значение атрибута integrity (Subresource Integrity).
+func ReadIntegrity(node *html.Node) string {
+	val, _ := readHTMLNodeAttrValue(node, "integrity")
+	return val
+}
+
+func WriteIntegrity(node *html.Node, integrity string) bool {
+	return setHTMLNodeAttrValue(node, "integrity", integrity)
+}
+
+// StripIntegrity удаляет integrity и crossorigin: без них ресурс грузится и из локальной копии (file://).
+func StripIntegrity(node *html.Node) bool {
+	removed := removeHTMLNodeAttr(node, "integrity")
+	removeHTMLNodeAttr(node, "crossorigin")
+	return removed
+}
+
+// assetLinkRels - значения rel у <link>, ресурсы которых нужны для офлайн-копии.
+var assetLinkRels = []string{
+	"stylesheet",
+	"icon",
+	"apple-touch-icon",
+	"apple-touch-icon-precomposed",
+	"mask-icon",
+	"manifest",
+	"preload",
+	"modulepreload",
+}
+
+func isAssetLinkRel(rel string) bool {
+	return slices.Contains(assetLinkRels, rel)
+}
+
+// readLinkRel returns lower-cased rel tokens, e.g. "shortcut icon" -> ["shortcut", "icon"].
+func readLinkRel(node *html.Node) []string {
+	relAttr, _ := readHTMLNodeAttrValue(node, "rel")
+	return strings.Fields(strings.ToLower(relAttr))
+}
+
 // collect обходит все узлы и собирает рекурсивно HTMLResource
 func collect(node *html.Node, tags []string, match func(*html.Node) (*HTMLResource, bool)) []*HTMLResource {
 	var res []*HTMLResource
@@ -116,3 +291,13 @@ func setHTMLNodeAttrValue(node *html.Node, attrName string, attrValue string) bo
 	}
 	return false
 }
+
+func removeHTMLNodeAttr(node *html.Node, attrName string) bool {
+	for i, attr := range node.Attr {
+		if attr.Key == attrName {
+			node.Attr = slices.Delete(node.Attr, i, i+1)
+			return true
+		}
+	}
+	return false
+}
@@ -71,3 +71,124 @@ func assertSomeResourcesFounds(t *testing.T, got []string, want []string) {
 		}
 	}
 }
+
+func TestReadRedirectURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		wantURL  string
+		wantKind string
+	}{
+		{"meta_refresh", `<meta http-equiv="refresh" content="0;url=/next.html">`, "/next.html", RedirectMetaRefresh},
+		{"meta_refresh_spaces_quotes", `<meta http-equiv="Refresh" content="5; URL = 'next.html'">`, "next.html", RedirectMetaRefresh},
+		{"meta_refresh_without_url_key", `<meta http-equiv="refresh" content="3; https://example.com/">`, "https://example.com/", RedirectMetaRefresh},
+		{"meta_refresh_reload_only", `<meta http-equiv="refresh" content="30">`, "", ""},
+		{"window_location", `<script>window.location = "/moved.html";</script>`, "/moved.html", RedirectScript},
+		{"location_href", `<script>if (true) { location.href='moved.html' }</script>`, "moved.html", RedirectScript},
+		{"location_replace", `<script>window.location.replace("https://example.com/new")</script>`, "https://example.com/new", RedirectScript},
+		{"location_compare", `<script>if (location == "x") {}</script>`, "", ""},
+		{"external_script", `<script src="/app.js"></script>`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, resources, err := ParseHTMLResources([]byte("<html><head>" + tt.html + "</head></html>"))
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			var gotURL, gotKind string
+			for _, res := range resources {
+				if res.Redirect != "" {
+					gotURL, gotKind = res.SourceURL, res.Redirect
+				}
+			}
+
+			if gotURL != tt.wantURL || gotKind != tt.wantKind {
+				t.Errorf("got (%q, %q), want (%q, %q)", gotURL, gotKind, tt.wantURL, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestWriteResourceURL(t *testing.T) {
+	t.Run("meta_refresh_keeps_delay", func(t *testing.T) {
+		_, resources, err := ParseHTMLResources([]byte(`<meta http-equiv="refresh" content="5; url=/next.html">`))
+		if err != nil || len(resources) != 1 {
+			t.Fatalf("failed to parse: %v, %v", err, resources)
+		}
+
+		if !WriteResourceURL(resources[0].Node, "./next.html") {
+			t.Fatalf("want meta refresh to be rewritten")
+		}
+
+		got, _ := readHTMLNodeAttrValue(resources[0].Node, "content")
+		if got != "5;url=./next.html" {
+			t.Errorf("got %q, want %q", got, "5;url=./next.html")
+		}
+	})
+}
+
+func TestReadResourceURLLinkRel(t *testing.T) {
+	_, resources, err := ParseHTMLResources([]byte(`<html><head>
+<link rel="shortcut icon" href="/favicon.ico">
+<link rel="apple-touch-icon" sizes="180x180" href="/apple-touch-icon.png">
+<link rel="manifest" href="/site.webmanifest">
+<link rel="preload" as="font" href="/font.woff2">
+<link rel="modulepreload" href="/app.mjs">
+<link rel="canonical" href="/canonical.html">
+<link rel="alternate" hreflang="de" href="/de/">
+</head></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	var got []string
+	for _, res := range resources {
+		got = append(got, res.SourceURL)
+	}
+
+	assertSomeResourcesFounds(t, got, []string{"/favicon.ico", "/apple-touch-icon.png", "/site.webmanifest", "/font.woff2", "/app.mjs"})
+
+	for _, notWant := range []string{"/canonical.html", "/de/"} {
+		if slices.Contains(got, notWant) {
+			t.Errorf("%q should not be collected", notWant)
+		}
+	}
+}
+
+func TestFindCanonicalURL(t *testing.T) {
+	rootNode, _, err := ParseHTMLResources([]byte(`<html><head>
+<link rel="alternate" hreflang="de" href="/de/">
+<link rel="Canonical" href="/canonical.html">
+<link rel="canonical" href="/second.html">
+</head></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if got, ok := FindCanonicalURL(rootNode); !ok || got != "/canonical.html" {
+		t.Errorf("got %q, %t", got, ok)
+	}
+
+	rootNode, _, _ = ParseHTMLResources([]byte(`<html><head><link rel="icon" href="/favicon.ico"></head></html>`))
+	if got, ok := FindCanonicalURL(rootNode); ok {
+		t.Errorf("got %q for a page without canonical", got)
+	}
+}
+
+func TestVisibleText(t *testing.T) {
+	rootNode, _, err := ParseHTMLResources([]byte(`<html><head><title>Title</title><style>p {}</style></head><body>
+<h1>Article</h1>
+<script>var hidden = 1;</script>
+<p>First <b>paragraph</b>.</p><!-- comment -->
+<noscript>Enable javascript</noscript>
+</body></html>`))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if got := VisibleText(rootNode); got != "Article First paragraph ." {
+		t.Errorf("got %q", got)
+	}
+}
@@ -2,76 +2,231 @@ package httpclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	urllib "net/url"
+	"slices"
+	"strings"
 	"time"
 )
 
 const (
 	defaultUserAgent = "Mozilla/5.0 (Linux; Android 8.0.0; SM-G955U Build/R16NW)"
-	defaultTimeout   = 30 * time.Second
+	// defaultBodyTimeout - остальные этапы запроса ограничены таймаутами transport (TransportConfig).
+	defaultBodyTimeout = 60 * time.Second
 )
 
+// ErrBodyTimeout - тело ответа не прочитано за отведенное время.
+var ErrBodyTimeout = errors.New("response body read timeout")
+
 type Client struct {
-	client    *http.Client
-	userAgent string
+	client      *http.Client
+	transport   *http.Transport
+	userAgent   string
+	limits      Limits
+	guard       *DialGuard
+	bodyTimeout time.Duration
+	middlewares []Middleware
 }
 
 type OptionFunc func(*Client)
 
+// Response - прочитанный ответ сервера.
+type Response struct {
+	// Content - тело, декодированное согласно Content-Encoding.
+	Content []byte
+	// Raw - тело в том виде, в каком оно получено (для сохранения исходного ответа), без кодировки совпадает с Content.
+	Raw        []byte
+	Header     http.Header
+	StatusCode int
+	// URL - адрес, с которого получен ответ (после редиректов).
+	URL *urllib.URL
+	// Redirects - адреса, которые вернули редирект, по порядку начиная с запрошенного.
+	Redirects []string
+	// TLS - nil для ответов без TLS.
+	TLS *TLSInfo
+}
+
+// EncodedSize - сколько байт тела передано по сети.
+func (r *Response) EncodedSize() int {
+	return len(r.Raw)
+}
+
+// DecodedSize - размер тела после декодирования.
+func (r *Response) DecodedSize() int {
+	return len(r.Content)
+}
+
+func (r *Response) ContentType() string {
+	return r.Header.Get("Content-Type")
+}
+
+// NotModified - ответ 304 на условный запрос (GetIfModified), тела нет.
+func (r *Response) NotModified() bool {
+	return r.StatusCode == http.StatusNotModified
+}
+
+// Validators - ETag и Last-Modified прошлого ответа для условного запроса.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// NewClient создает клиент. Клиент безопасен для конкурентного использования, а без WithTransport
+// у каждого клиента свой пул соединений, поэтому краулер создает один клиент на все запросы.
 func NewClient(options ...OptionFunc) *Client {
 	f := &Client{
-		client:    &http.Client{Timeout: defaultTimeout},
-		userAgent: defaultUserAgent,
+		client:      &http.Client{},
+		userAgent:   defaultUserAgent,
+		bodyTimeout: defaultBodyTimeout,
 	}
 
 	for _, opt := range options {
 		opt(f)
 	}
 
+	if f.transport == nil {
+		cfg := DefaultTransportConfig()
+		cfg.DialGuard = f.guard
+		cfg.MaxHeaderSize = f.limits.MaxHeaderSize
+		f.transport = NewTransport(cfg)
+	}
+	f.client.Transport = chain(withProxyDial(f.transport), f.middlewares)
+
 	return f
 }
 
+// WithTimeout ограничивает запрос целиком, включая чтение тела.
 func WithTimeout(timeout time.Duration) OptionFunc {
 	return func(f *Client) {
 		f.client.Timeout = timeout
 	}
 }
 
+// WithBodyTimeout ограничивает чтение тела после получения заголовков, 0 - без ограничения.
+func WithBodyTimeout(timeout time.Duration) OptionFunc {
+	return func(f *Client) {
+		f.bodyTimeout = timeout
+	}
+}
+
 func WithUserAgent(ua string) OptionFunc {
 	return func(f *Client) {
 		f.userAgent = ua
 	}
 }
 
-func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
-	req, err := c.getRequest(url)
+func (c *Client) Get(ctx context.Context, url string) (*Response, error) {
+	req, err := c.getRequest(http.MethodGet, url)
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
 	}
 
+	return c.do(ctx, req)
+}
+
+// GetIfModified - условный GET (If-None-Match, If-Modified-Since): если ресурс не изменился,
+// возвращается ответ 304 без тела, см. Response.NotModified.
+func (c *Client) GetIfModified(ctx context.Context, url string, validators Validators) (*Response, error) {
+	req, err := c.getRequest(http.MethodGet, url)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	return c.do(ctx, req)
+}
+
+// PostForm отправляет форму (application/x-www-form-urlencoded), после редиректа 302/303 ответ получается GET-запросом.
+func (c *Client) PostForm(ctx context.Context, url string, values urllib.Values) (*Response, error) {
+	req, err := c.getRequest(http.MethodPost, url)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	body := values.Encode()
+	req.Body = io.NopCloser(strings.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.do(ctx, req)
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request) (*Response, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	resp, err := c.doRequest(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && isConditional(req) {
+		return &Response{
+			Header:     resp.Header,
+			StatusCode: resp.StatusCode,
+			URL:        resp.Request.URL,
+			Redirects:  redirectChain(resp),
+			TLS:        newTLSInfo(resp.TLS),
+		}, nil
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
 	}
 
-	content, err := io.ReadAll(resp.Body)
+	if c.bodyTimeout > 0 {
+		timer := time.AfterFunc(c.bodyTimeout, func() { cancel(ErrBodyTimeout) })
+		defer timer.Stop()
+	}
 
+	content, raw, err := c.readBody(resp)
 	if err != nil {
+		if errors.Is(context.Cause(ctx), ErrBodyTimeout) {
+			return nil, ErrBodyTimeout
+		}
 		return nil, err
 	}
 
-	return content, nil
+	return &Response{
+		Content:    content,
+		Raw:        raw,
+		Header:     resp.Header,
+		StatusCode: resp.StatusCode,
+		URL:        resp.Request.URL,
+		Redirects:  redirectChain(resp),
+		TLS:        newTLSInfo(resp.TLS),
+	}, nil
 }
 
 func (c *Client) Head(ctx context.Context, url string) (*http.Response, error) {
-	return c.client.Head(url)
+	req, err := c.getRequest(http.MethodHead, url)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+
+	return resp, nil
 }
 
 func (c *Client) doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
@@ -84,20 +239,40 @@ func (c *Client) doRequest(ctx context.Context, req *http.Request) (*http.Respon
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
-			return nil, fmt.Errorf("failed to make http request: %w", err)
 		}
+
+		if isHeaderLimitErr(err) {
+			return nil, &LimitError{Kind: LimitHeaderSize, Limit: c.limits.MaxHeaderSize}
+		}
+		return nil, fmt.Errorf("failed to make http request: %w", err)
 	}
 
 	return resp, nil
 }
 
-func (c *Client) getRequest(url string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// redirectChain восстанавливает редиректы по Request.Response, которые http.Client связывает в цепочку.
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		chain = append(chain, r.Request.URL.String())
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+func isConditional(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+}
+
+func (c *Client) getRequest(method string, url string) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to nit request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", c.userAgent)
+	// explicit header disables transparent decompression of the transport, body is decoded under the limits
+	req.Header.Set("Accept-Encoding", acceptEncoding)
 
 	return req, nil
 }
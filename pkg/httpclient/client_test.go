@@ -0,0 +1,82 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestClientGetIfModified(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	client := NewClient()
+
+	tests := []struct {
+		name            string
+		validators      Validators
+		wantNotModified bool
+	}{
+		{"no_validators", Validators{}, false},
+		{"stale_etag", Validators{ETag: `"v0"`}, false},
+		{"etag", Validators{ETag: etag}, true},
+		{"last_modified", Validators{LastModified: lastModified}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GetIfModified(t.Context(), srv.URL, tt.validators)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.NotModified() != tt.wantNotModified {
+				t.Fatalf("got status %d, wantNotModified %t", resp.StatusCode, tt.wantNotModified)
+			}
+			if !tt.wantNotModified && string(resp.Content) != "content" {
+				t.Errorf("got content %q", resp.Content)
+			}
+			if resp.Header.Get("ETag") != etag {
+				t.Errorf("got ETag %q, want %q", resp.Header.Get("ETag"), etag)
+			}
+		})
+	}
+
+	// 304 without a conditional request is unexpected
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	if _, err := client.Get(t.Context(), srv.URL); err == nil {
+		t.Fatal("want error")
+	}
+}
+
+func TestClientRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/a", http.RedirectHandler("/b", http.StatusFound))
+	mux.Handle("/b", http.RedirectHandler("/c", http.StatusMovedPermanently))
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := NewClient().Get(t.Context(), srv.URL+"/a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{srv.URL + "/a", srv.URL + "/b"}
+	if !slices.Equal(resp.Redirects, want) || resp.URL.String() != srv.URL+"/c" {
+		t.Errorf("got redirects %v to %s, want %v to /c", resp.Redirects, resp.URL, want)
+	}
+}
@@ -0,0 +1,277 @@
+package httpclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang.org/x/net/publicsuffix"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	urllib "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// Jar - cookie jar, который можно сохранить в Netscape cookies.txt и загрузить из него (например, экспорт сессии браузера).
+//
+// Выбор cookies для запроса делает стандартный cookiejar (domain/path/secure, public suffix list),
+// а Jar дополнительно хранит атрибуты установленных cookies, которые стандартный jar наружу не отдает.
+type Jar struct {
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	entries map[string]*jarEntry
+}
+
+type jarEntry struct {
+	Domain   string
+	HostOnly bool
+	Path     string
+	Secure   bool
+	HttpOnly bool
+	// Expires - нулевое значение для session cookie.
+	Expires time.Time
+	Name    string
+	Value   string
+}
+
+func (e *jarEntry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func NewJar() (*Jar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Jar{jar: jar, entries: map[string]*jarEntry{}}, nil
+}
+
+// WithCookieJar задает jar для всех запросов клиента (в том числе для запросов после редиректов).
+func WithCookieJar(jar http.CookieJar) OptionFunc {
+	return func(f *Client) {
+		f.client.Jar = jar
+	}
+}
+
+func (j *Jar) Cookies(u *urllib.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *Jar) SetCookies(u *urllib.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		entry, ok := newJarEntry(u, cookie, now)
+		if !ok {
+			continue
+		}
+
+		if cookie.MaxAge < 0 || (!entry.Expires.IsZero() && !entry.Expires.After(now)) {
+			delete(j.entries, entry.key())
+			continue
+		}
+		j.entries[entry.key()] = entry
+	}
+}
+
+// newJarEntry повторяет правила cookiejar для domain и path, чтобы хранить cookie под тем же ключом.
+func newJarEntry(u *urllib.URL, cookie *http.Cookie, now time.Time) (*jarEntry, bool) {
+	host := strings.ToLower(u.Hostname())
+
+	entry := &jarEntry{
+		Domain:   host,
+		HostOnly: true,
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+	}
+
+	if domain := strings.ToLower(strings.TrimPrefix(cookie.Domain, ".")); domain != "" && domain != host {
+		if !strings.HasSuffix(host, "."+domain) {
+			return nil, false
+		}
+		if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+			return nil, false
+		}
+		entry.Domain = domain
+		entry.HostOnly = false
+	} else if cookie.Domain != "" {
+		entry.HostOnly = false
+	}
+
+	if entry.Path == "" || entry.Path[0] != '/' {
+		entry.Path = defaultCookiePath(u.Path)
+	}
+
+	switch {
+	case cookie.MaxAge > 0:
+		entry.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		entry.Expires = cookie.Expires
+	}
+
+	return entry, true
+}
+
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// ReadNetscape загружает cookies в формате Netscape cookies.txt:
+// domain, include subdomains, path, secure, expires (unix), name, value - через табуляцию.
+func (j *Jar) ReadNetscape(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("cookies line %d: want 7 tab-separated fields, got %d", lineNum, len(fields))
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cookies line %d: invalid expires %q", lineNum, fields[4])
+		}
+
+		domain := strings.TrimPrefix(fields[0], ".")
+		secure := strings.EqualFold(fields[3], "TRUE")
+
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		j.SetCookies(&urllib.URL{Scheme: scheme, Host: domain, Path: cookie.Path}, []*http.Cookie{cookie})
+	}
+
+	return scanner.Err()
+}
+
+// WriteNetscape пишет непросроченные cookies в формате Netscape cookies.txt.
+func (j *Jar) WriteNetscape(w io.Writer) error {
+	j.mu.Lock()
+	entries := make([]*jarEntry, 0, len(j.entries))
+	now := time.Now()
+	for _, entry := range j.entries {
+		if entry.Expires.IsZero() || entry.Expires.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+	j.mu.Unlock()
+
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].key() < entries[b].key()
+	})
+
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+
+	for _, entry := range entries {
+		domain, includeSubdomains := entry.Domain, "FALSE"
+		if !entry.HostOnly {
+			domain, includeSubdomains = "."+entry.Domain, "TRUE"
+		}
+		if entry.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+
+		var expires int64
+		if !entry.Expires.IsZero() {
+			expires = entry.Expires.Unix()
+		}
+
+		_, _ = fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, includeSubdomains, entry.Path, netscapeBool(entry.Secure), expires, entry.Name, entry.Value)
+	}
+
+	return bw.Flush()
+}
+
+func netscapeBool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// Load загружает cookies из файла, отсутствующий файл - пустой jar.
+func (j *Jar) Load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open cookies file: %w", err)
+	}
+	defer f.Close()
+
+	if err := j.ReadNetscape(f); err != nil {
+		return fmt.Errorf("read cookies file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Save пишет cookies через временный файл, права 0600: в файле сессии.
+func (j *Jar) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	tmpFile := path + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("create cookies file: %w", err)
+	}
+
+	if err := j.WriteNetscape(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write cookies file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write cookies file: %w", err)
+	}
+
+	return os.Rename(tmpFile, path)
+}
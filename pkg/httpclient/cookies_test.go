@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	urllib "net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJarNetscapeRoundTrip(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour).Unix()
+	input := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		".example.com\tTRUE\t/\tFALSE\t" + strconv.FormatInt(expires, 10) + "\tconsent\tyes",
+		"#HttpOnly_docs.example.com\tFALSE\t/private\tTRUE\t0\tsession\tabc",
+		"old.example.com\tFALSE\t/\tFALSE\t1\texpired\tx",
+	}, "\n")
+
+	jar, err := NewJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.ReadNetscape(strings.NewReader(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://example.com/", "consent=yes"},
+		{"http://www.example.com/a", "consent=yes"},
+		{"https://docs.example.com/private/page", "session=abc; consent=yes"},
+		{"http://docs.example.com/private/page", "consent=yes"},
+		{"http://sub.docs.example.com/private/page", "consent=yes"},
+		{"http://old.example.com/", "consent=yes"},
+		{"http://example.org/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, _ := urllib.Parse(tt.url)
+			if got := cookieHeader(jar.Cookies(u)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	if err := jar.WriteNetscape(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_docs.example.com\tFALSE\t/private\tTRUE\t0\tsession\tabc\n" +
+		".example.com\tTRUE\t/\tFALSE\t" + strconv.FormatInt(expires, 10) + "\tconsent\tyes\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestJarReadNetscapeInvalid(t *testing.T) {
+	jar, _ := NewJar()
+	if err := jar.ReadNetscape(strings.NewReader("example.com\tFALSE\t/\n")); err == nil {
+		t.Fatal("want error")
+	}
+}
+
+func TestClientWithCookieJar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "42", Path: "/"})
+			http.Redirect(w, r, "/docs", http.StatusFound)
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Path: "/", MaxAge: -1})
+		case "/docs":
+			if c, err := r.Cookie("sid"); err != nil || c.Value != "42" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	jar, _ := NewJar()
+	client := NewClient(WithCookieJar(jar))
+
+	if _, err := client.Get(t.Context(), srv.URL+"/docs"); err == nil {
+		t.Fatal("want error without a session")
+	}
+	if _, err := client.Get(t.Context(), srv.URL+"/login"); err != nil {
+		t.Fatalf("cookie was not sent after redirect: %v", err)
+	}
+
+	var out bytes.Buffer
+	_ = jar.WriteNetscape(&out)
+	if !strings.Contains(out.String(), "\tsid\t42") {
+		t.Errorf("cookie is not exported: %q", out.String())
+	}
+
+	if _, err := client.Get(t.Context(), srv.URL+"/logout"); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	_ = jar.WriteNetscape(&out)
+	if strings.Contains(out.String(), "sid") {
+		t.Errorf("deleted cookie is exported: %q", out.String())
+	}
+}
+
+func cookieHeader(cookies []*http.Cookie) string {
+	var parts []string
+	for _, c := range cookies {
+		parts = append(parts, c.Name+"="+c.Value)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,87 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DiskCache - Cache в каталоге, переживает перезапуск. Запись лежит в <dir>/<hh>/<sha256(key)>:
+// первая строка - json с кодом и заголовками, дальше тело как есть (без декодирования Content-Encoding).
+//
+// Cache не возвращает ошибок, поэтому испорченная или недоступная запись считается промахом,
+// а неудачная запись пропускается.
+type DiskCache struct {
+	dir string
+}
+
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{dir: dir}
+}
+
+func (c *DiskCache) Get(key string) (*CachedResponse, bool) {
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	meta, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, false
+	}
+
+	var resp CachedResponse
+	if err := json.Unmarshal(meta, &resp); err != nil {
+		return nil, false
+	}
+	if resp.Body, err = io.ReadAll(r); err != nil {
+		return nil, false
+	}
+
+	return &resp, true
+}
+
+func (c *DiskCache) Set(key string, resp *CachedResponse) {
+	meta := *resp
+	meta.Body = nil
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	// the same url can be stored concurrently, a reader sees either the old or the new entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(tmp, io.MultiReader(bytes.NewReader(data), bytes.NewReader([]byte{'\n'}), bytes.NewReader(resp.Body)))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
@@ -0,0 +1,97 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"strings"
+)
+
+// acceptEncoding - кодировки, которые клиент умеет декодировать сам (в порядке предпочтения).
+const acceptEncoding = "br, zstd, gzip, deflate"
+
+func isIdentityEncoding(contentEncoding string) bool {
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		if c := strings.ToLower(strings.TrimSpace(coding)); c != "" && c != "identity" {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeBody снимает кодировки из Content-Encoding. Они перечислены в порядке применения, поэтому снимаются с конца.
+func decodeBody(r io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	codings := strings.Split(contentEncoding, ",")
+
+	decoded := io.NopCloser(r)
+	var closers []io.Closer
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		decoded, err = decodeReader(decoded, strings.ToLower(strings.TrimSpace(codings[i])))
+		if err != nil {
+			closeAll(closers)
+			return nil, err
+		}
+		closers = append(closers, decoded)
+	}
+
+	return &decodedReader{Reader: decoded, closers: closers}, nil
+}
+
+func decodeReader(r io.Reader, coding string) (io.ReadCloser, error) {
+	switch coding {
+	case "", "identity":
+		return io.NopCloser(r), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case "zstd":
+		// single goroutine and bounded window: the decoder memory should not depend on the server
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(32<<20))
+		if err != nil {
+			return nil, fmt.Errorf("decode zstd: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip: %w", err)
+		}
+		return zr, nil
+	case "deflate":
+		// "deflate" should be zlib, but some servers send raw deflate
+		br := bufio.NewReader(r)
+		header, _ := br.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("decode deflate: %w", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", coding)
+	}
+}
+
+type decodedReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decodedReader) Close() error {
+	closeAll(d.closers)
+	return nil
+}
+
+func closeAll(closers []io.Closer) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		_ = closers[i].Close()
+	}
+}
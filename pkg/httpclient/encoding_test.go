@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func encodeBytes(t *testing.T, coding string, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = zw
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = fw
+	default:
+		t.Fatalf("unknown coding %q", coding)
+	}
+
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestClientGetContentEncoding(t *testing.T) {
+	content := []byte(strings.Repeat("<p>hello, world</p>\n", 100))
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            func(t *testing.T) []byte
+	}{
+		{"identity", "", func(t *testing.T) []byte { return content }},
+		{"br", "br", func(t *testing.T) []byte { return encodeBytes(t, "br", content) }},
+		{"zstd", "zstd", func(t *testing.T) []byte { return encodeBytes(t, "zstd", content) }},
+		{"gzip", "gzip", func(t *testing.T) []byte { return encodeBytes(t, "gzip", content) }},
+		{"deflate", "deflate", func(t *testing.T) []byte { return encodeBytes(t, "deflate", content) }},
+		{"raw_deflate", "deflate", func(t *testing.T) []byte { return encodeBytes(t, "raw-deflate", content) }},
+		{"gzip_then_br", "gzip, br", func(t *testing.T) []byte {
+			return encodeBytes(t, "br", encodeBytes(t, "gzip", content))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body(t)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != acceptEncoding {
+					t.Errorf("Accept-Encoding = %q, want %q", got, acceptEncoding)
+				}
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				_, _ = w.Write(body)
+			}))
+			defer srv.Close()
+
+			resp, err := NewClient().Get(t.Context(), srv.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !bytes.Equal(resp.Content, content) {
+				t.Errorf("decoded content mismatch")
+			}
+			if !bytes.Equal(resp.Raw, body) {
+				t.Errorf("raw body mismatch")
+			}
+			if resp.EncodedSize() != len(body) || resp.DecodedSize() != len(content) {
+				t.Errorf("sizes = %d/%d, want %d/%d", resp.EncodedSize(), resp.DecodedSize(), len(body), len(content))
+			}
+		})
+	}
+}
+
+func TestClientGetUnsupportedEncoding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "compress")
+		_, _ = w.Write([]byte("x"))
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient().Get(t.Context(), srv.URL); err == nil {
+		t.Fatal("want error")
+	}
+}
@@ -0,0 +1,109 @@
+package httpclient
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// DefaultDeniedNetworks - адреса, к которым краулер, работающий внутри кластера, не должен подключаться:
+// loopback, private, link-local (в т.ч. cloud metadata 169.254.169.254), CGNAT, multicast и т.п.
+// IPv6-сети, в адреса которых встроен IPv4 (NAT64, 6to4, Teredo), закрыты целиком: через шлюз они могут вести
+// к любому из IPv4-адресов выше.
+var DefaultDeniedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// BlockedAddressError - соединение с адресом запрещено DialGuard, повторять запрос нет смысла.
+type BlockedAddressError struct {
+	Addr string
+}
+
+func (e *BlockedAddressError) Error() string {
+	return fmt.Sprintf("connection to %s is blocked", e.Addr)
+}
+
+// DialGuard проверяет адрес, с которым реально устанавливается соединение (уже после DNS resolve).
+// Поэтому DNS rebinding между проверкой и подключением невозможен, а редиректы проверяются так же, как и первый запрос.
+type DialGuard struct {
+	// Deny - запрещенные сети, Allow - исключения из них (имеют приоритет).
+	Deny  []netip.Prefix
+	Allow []netip.Prefix
+}
+
+// NewDialGuard создает guard с DefaultDeniedNetworks и дополнительными сетями.
+// allowPrivate отключает DefaultDeniedNetworks, например для обхода локальных тестовых сайтов.
+func NewDialGuard(allowPrivate bool, deny []netip.Prefix, allow []netip.Prefix) *DialGuard {
+	g := &DialGuard{Allow: allow}
+	if !allowPrivate {
+		g.Deny = append(g.Deny, DefaultDeniedNetworks...)
+	}
+	g.Deny = append(g.Deny, deny...)
+	return g
+}
+
+// Check проверяет адрес вида "ip:port".
+func (g *DialGuard) Check(address string) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &BlockedAddressError{Addr: address}
+	}
+
+	ip := addrPort.Addr().Unmap()
+
+	for _, prefix := range g.Allow {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+
+	for _, prefix := range g.Deny {
+		if prefix.Contains(ip) {
+			return &BlockedAddressError{Addr: address}
+		}
+	}
+
+	return nil
+}
+
+// control вызывается net.Dialer для каждого адреса непосредственно перед connect.
+func (g *DialGuard) control(network, address string, _ syscall.RawConn) error {
+	return g.Check(address)
+}
+
+// Dialer возвращает dialer, который отказывается подключаться к запрещенным адресам.
+func (g *DialGuard) Dialer(timeout, keepAlive time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: keepAlive,
+		Control:   g.control,
+	}
+}
+
+// WithDialGuard проверяет все соединения клиента, в том числе после редиректов.
+// Для общего transport guard задается в TransportConfig.
+func WithDialGuard(g *DialGuard) OptionFunc {
+	return func(f *Client) {
+		f.guard = g
+	}
+}
@@ -0,0 +1,100 @@
+package httpclient
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestDialGuardCheck(t *testing.T) {
+	guard := NewDialGuard(false, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}, []netip.Prefix{netip.MustParsePrefix("10.1.2.3/32")})
+
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"93.184.216.34:443", false},
+		{"127.0.0.1:80", true},
+		{"169.254.169.254:80", true},
+		{"10.0.0.1:80", true},
+		{"10.1.2.3:80", false},
+		{"[::1]:80", true},
+		{"[::ffff:127.0.0.1]:80", true},
+		{"[fe80::1]:80", true},
+		{"[64:ff9b::7f00:1]:80", true},
+		{"[2002:7f00:1::1]:80", true},
+		{"[2001:0:4136:e378:8000:63bf:3fff:fdd2]:80", true},
+		{"[2606:4700:4700::1111]:443", false},
+		{"203.0.113.7:80", true},
+		{"garbage", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := guard.Check(tt.addr)
+			if (err != nil) != tt.blocked {
+				t.Errorf("Check(%q) = %v, want blocked %v", tt.addr, err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestWithDialGuard(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	t.Run("loopback_blocked_by_default", func(t *testing.T) {
+		client := NewClient(WithDialGuard(NewDialGuard(false, nil, nil)))
+
+		_, err := client.Get(t.Context(), srv.URL)
+
+		var blocked *BlockedAddressError
+		if !errors.As(err, &blocked) {
+			t.Fatalf("want *BlockedAddressError, got %v", err)
+		}
+	})
+
+	t.Run("loopback_allowed_explicitly", func(t *testing.T) {
+		client := NewClient(WithDialGuard(NewDialGuard(true, nil, nil)))
+
+		resp, err := client.Get(t.Context(), srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(resp.Content) != "ok" {
+			t.Errorf("got %q", resp.Content)
+		}
+	})
+
+	t.Run("redirect_to_blocked", func(t *testing.T) {
+		// the whole 127.0.0.0/8 is loopback on linux, but it is not guaranteed on other systems
+		ln, err := net.Listen("tcp", "127.0.0.2:0")
+		if err != nil {
+			t.Skipf("127.0.0.2 is not available: %v", err)
+		}
+
+		internalSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("secret"))
+		}))
+		internalSrv.Listener = ln
+		internalSrv.Start()
+		defer internalSrv.Close()
+
+		redirectSrv := httptest.NewServer(http.RedirectHandler(internalSrv.URL, http.StatusFound))
+		defer redirectSrv.Close()
+
+		client := NewClient(WithDialGuard(NewDialGuard(false, nil, []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")})))
+
+		_, err = client.Get(t.Context(), redirectSrv.URL)
+
+		var blocked *BlockedAddressError
+		if !errors.As(err, &blocked) {
+			t.Fatalf("want *BlockedAddressError, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,450 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heuristicMaxLifetime ограничивает эвристическую свежесть по Last-Modified.
+const heuristicMaxLifetime = 24 * time.Hour
+
+// cacheDrainLimit - сколько тела дочитывается при Close, чтобы ответ попал в кэш: декодеры могут закончить
+// чтение раньше EOF исходного потока.
+const cacheDrainLimit = 4 << 10
+
+// NotCachedError - ответа нет в кэше, а сеть недоступна (HTTPCacheMiddleware в режиме offline).
+type NotCachedError struct {
+	URL string
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("%s is not in the cache", e.URL)
+}
+
+func (e *NotCachedError) Permanent() bool {
+	return true
+}
+
+// HTTPCacheMiddleware - приватный кэш по RFC 9111 поверх cache (обычно DiskCache):
+//   - хранятся GET-ответы с кодами, кэшируемыми по умолчанию, кроме no-store, включая уже устаревшие - они пригодятся для offline и условных запросов
+//   - свежесть считается по max-age, Expires и эвристически по Last-Modified с учетом Age и Date
+//   - устаревший ответ перепроверяется запросом с If-None-Match/If-Modified-Since, на 304 обновляются заголовки
+//   - ответ отдается, только если совпали заголовки запроса из Vary, "Vary: *" не кэшируется
+//   - HEAD отдается из ответа на GET
+//
+// offline не обращается к сети: ответ отдается из кэша независимо от свежести, промах - NotCachedError.
+func HTTPCacheMiddleware(cache Cache, offline bool) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &httpCache{cache: cache, offline: offline, next: next, now: time.Now}
+	}
+}
+
+type httpCache struct {
+	cache   Cache
+	offline bool
+	next    http.RoundTripper
+	now     func() time.Time
+}
+
+func (c *httpCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		if c.offline {
+			return nil, &NotCachedError{URL: key}
+		}
+		return c.next.RoundTrip(req)
+	}
+
+	directives := parseCacheControl(req.Header)
+	if _, ok := directives["no-store"]; ok && !c.offline {
+		return c.next.RoundTrip(req)
+	}
+
+	entry, ok := c.cache.Get(key)
+	if ok && !entry.matchesVary(req) {
+		entry, ok = nil, false
+	}
+
+	if _, onlyIfCached := directives["only-if-cached"]; c.offline || onlyIfCached {
+		if !ok {
+			return nil, &NotCachedError{URL: key}
+		}
+		return entry.serve(req, c.now()), nil
+	}
+
+	if ok && c.isFresh(entry, directives) {
+		return entry.serve(req, c.now()), nil
+	}
+	if req.Method == http.MethodHead {
+		return c.next.RoundTrip(req)
+	}
+	if ok && entry.hasValidators() {
+		return c.revalidate(req, key, entry)
+	}
+
+	requestTime := c.now()
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return c.store(req, key, resp, requestTime), nil
+}
+
+// revalidate заменяет условия запроса валидаторами entry. На 304 вызывающему отдается обновленная копия
+// (или 304, если совпали уже его условия), на другой ответ - сам ответ.
+func (c *httpCache) revalidate(req *http.Request, key string, entry *CachedResponse) (*http.Response, error) {
+	conditional := req.Clone(req.Context())
+	conditional.Header.Del("If-None-Match")
+	conditional.Header.Del("If-Modified-Since")
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := c.now()
+	resp, err := c.next.RoundTrip(conditional)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		return c.store(req, key, resp, requestTime), nil
+	}
+	_ = resp.Body.Close()
+
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for name, values := range storedHeader(resp.Header) {
+		if name != "Content-Length" {
+			updated.Header[name] = values
+		}
+	}
+	updated.RequestTime, updated.ResponseTime = requestTime, c.now()
+	c.cache.Set(key, &updated)
+
+	served := updated.serve(req, c.now())
+	served.TLS = resp.TLS
+	// cookies of this response still reach the cookie jar, they are just not stored
+	for _, name := range CookieHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			served.Header[name] = values
+		}
+	}
+	return served, nil
+}
+
+// store подменяет тело ответа: кэш получает копию, когда тело дочитано до конца.
+func (c *httpCache) store(req *http.Request, key string, resp *http.Response, requestTime time.Time) *http.Response {
+	if !isStorable(req, resp) {
+		return resp
+	}
+
+	entry := &CachedResponse{
+		StatusCode:    resp.StatusCode,
+		Header:        storedHeader(resp.Header),
+		RequestHeader: varyHeader(req, resp.Header),
+		RequestTime:   requestTime,
+		ResponseTime:  c.now(),
+	}
+	resp.Body = &cachingBody{ReadCloser: resp.Body, complete: func(body []byte) {
+		entry.Body = body
+		c.cache.Set(key, entry)
+	}}
+
+	return resp
+}
+
+// CookieHeaders - заголовки, которыми ответ ставит куки сессии. Они не сохраняются ни в кэше, ни в копии сайта:
+// и то и другое переживает обход.
+var CookieHeaders = []string{"Set-Cookie", "Set-Cookie2"}
+
+// storedHeader - копия заголовков ответа для кэша, без CookieHeaders.
+func storedHeader(header http.Header) http.Header {
+	res := header.Clone()
+	for _, name := range CookieHeaders {
+		res.Del(name)
+	}
+	return res
+}
+
+func (c *httpCache) isFresh(entry *CachedResponse, directives map[string]string) bool {
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+	if _, ok := parseCacheControl(entry.Header)["no-cache"]; ok {
+		return false
+	}
+
+	age := entry.age(c.now())
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil && age > time.Duration(seconds)*time.Second {
+			return false
+		}
+	}
+
+	return age < entry.freshnessLifetime()
+}
+
+// freshnessLifetime - RFC 9111 4.2.1, s-maxage пропускается: кэш приватный.
+func (c *CachedResponse) freshnessLifetime() time.Duration {
+	if maxAge, ok := parseCacheControl(c.Header)["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	date := c.date()
+	if expires := c.Header.Get("Expires"); expires != "" {
+		// invalid Expires means already expired
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(date)
+	}
+
+	if lastModified, err := http.ParseTime(c.Header.Get("Last-Modified")); err == nil && isHeuristicallyCacheable(c.StatusCode) {
+		return min(date.Sub(lastModified)/10, heuristicMaxLifetime)
+	}
+
+	return 0
+}
+
+// age - RFC 9111 4.2.3.
+func (c *CachedResponse) age(now time.Time) time.Duration {
+	apparentAge := max(c.ResponseTime.Sub(c.date()), 0)
+
+	var ageValue time.Duration
+	if seconds, err := strconv.Atoi(c.Header.Get("Age")); err == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	correctedAge := ageValue + c.ResponseTime.Sub(c.RequestTime)
+
+	return max(apparentAge, correctedAge) + now.Sub(c.ResponseTime)
+}
+
+func (c *CachedResponse) date() time.Time {
+	if date, err := http.ParseTime(c.Header.Get("Date")); err == nil {
+		return date
+	}
+	return c.ResponseTime
+}
+
+func (c *CachedResponse) hasValidators() bool {
+	return c.Header.Get("ETag") != "" || c.Header.Get("Last-Modified") != ""
+}
+
+func (c *CachedResponse) matchesVary(req *http.Request) bool {
+	for _, name := range varyNames(c.Header) {
+		if name == "*" {
+			return false
+		}
+		if strings.Join(req.Header.Values(name), ", ") != strings.Join(c.RequestHeader.Values(name), ", ") {
+			return false
+		}
+	}
+	return true
+}
+
+// serve отдает сохраненный ответ или 304, если условия запроса выполнены для сохраненного ответа.
+func (c *CachedResponse) serve(req *http.Request, now time.Time) *http.Response {
+	resp := c.response(req)
+	resp.Header.Set("Age", strconv.Itoa(int(c.age(now).Seconds())))
+
+	if isConditional(req) && c.matchesConditions(req) {
+		resp.StatusCode, resp.Status = http.StatusNotModified, http.StatusText(http.StatusNotModified)
+		resp.Header.Del("Content-Length")
+		resp.Body, resp.ContentLength = http.NoBody, 0
+	} else if req.Method == http.MethodHead {
+		resp.Body = http.NoBody
+	}
+
+	return resp
+}
+
+// matchesConditions - RFC 9110 13.2.2: If-Modified-Since проверяется, только если нет If-None-Match.
+func (c *CachedResponse) matchesConditions(req *http.Request) bool {
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := c.Header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakETag(candidate) == weakETag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(c.Header.Get("Last-Modified"))
+	return err == nil && !lastModified.After(ifModifiedSince)
+}
+
+func weakETag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+func isStorable(req *http.Request, resp *http.Response) bool {
+	if req.Method != http.MethodGet || resp.StatusCode == http.StatusPartialContent {
+		return false
+	}
+	if _, ok := parseCacheControl(resp.Header)["no-store"]; ok {
+		return false
+	}
+	if slices.Contains(varyNames(resp.Header), "*") {
+		return false
+	}
+	if !isShared(req, resp.Header) {
+		return false
+	}
+
+	if isHeuristicallyCacheable(resp.StatusCode) {
+		return true
+	}
+	// other codes only with explicit freshness
+	_, maxAge := parseCacheControl(resp.Header)["max-age"]
+	return maxAge || resp.Header.Get("Expires") != ""
+}
+
+// isShared - ответ на запрос с учетными данными одинаков для всех: явно public или учетные данные перечислены в Vary
+// (тогда они проверяются при выдаче, см. matchesVary). Кэш на диске переживает обход, и иначе ответ для одного
+// пользователя или сессии достался бы следующему обходу с другими учетными данными или вовсе без них.
+func isShared(req *http.Request, header http.Header) bool {
+	if _, ok := parseCacheControl(header)["public"]; ok {
+		return true
+	}
+
+	vary := varyNames(header)
+	for _, name := range credentialHeaders(req) {
+		if !slices.Contains(vary, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// credentialHeaders - заголовки запроса с учетными данными: Authorization, Cookie (cookie jar, login) и заголовки,
+// которые добавил HostAuthMiddleware.
+func credentialHeaders(req *http.Request) []string {
+	var names []string
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if req.Header.Get(name) != "" {
+			names = append(names, name)
+		}
+	}
+	if authHeaders, ok := req.Context().Value(authHeadersKey{}).([]string); ok {
+		for _, name := range authHeaders {
+			if name = http.CanonicalHeaderKey(name); !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// isHeuristicallyCacheable - RFC 9110 15.1.
+func isHeuristicallyCacheable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+func varyHeader(req *http.Request, respHeader http.Header) http.Header {
+	names := varyNames(respHeader)
+	if len(names) == 0 {
+		return nil
+	}
+
+	header := http.Header{}
+	for _, name := range names {
+		for _, value := range req.Header.Values(name) {
+			header.Add(name, value)
+		}
+	}
+	return header
+}
+
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// parseCacheControl возвращает директивы Cache-Control в нижнем регистре, значения без кавычек.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+// cacheKey - url без фрагмента, HEAD и GET используют одну запись.
+func cacheKey(req *http.Request) string {
+	u := *req.URL
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+// cachingBody копит прочитанное тело и передает его в complete, когда тело прочитано до EOF.
+type cachingBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	done     bool
+	complete func([]byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *cachingBody) Close() error {
+	if !b.done {
+		// a decoder may stop before EOF of the raw stream, a truncated body is not stored
+		if _, err := io.CopyN(&b.buf, b.ReadCloser, cacheDrainLimit); err == io.EOF {
+			b.finish()
+		}
+	}
+	return b.ReadCloser.Close()
+}
+
+func (b *cachingBody) finish() {
+	if !b.done {
+		b.done = true
+		b.complete(bytes.Clone(b.buf.Bytes()))
+	}
+}
@@ -0,0 +1,334 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	urllib "net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryCache - Cache в памяти для тестов.
+type memoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]*CachedResponse
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: map[string]*CachedResponse{}}
+}
+
+func (c *memoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	resp, ok := c.entries[key]
+	return resp, ok
+}
+
+func (c *memoryCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = resp
+}
+
+func TestHTTPCacheMiddleware(t *testing.T) {
+	var hits, revalidated atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/no-cache":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				revalidated.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/expired":
+			w.Header().Set("Expires", "Mon, 02 Jan 2006 15:04:05 GMT")
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path            string
+		wantHits        int32
+		wantRevalidated int32
+	}{
+		{"/fresh", 1, 0},
+		{"/no-cache", 3, 2},
+		{"/no-store", 3, 0},
+		{"/expired", 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			hits.Store(0)
+			revalidated.Store(0)
+			client := NewClient(WithMiddleware(HTTPCacheMiddleware(newMemoryCache(), false)))
+
+			for range 3 {
+				resp, err := client.Get(t.Context(), srv.URL+tt.path)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(resp.Content) != "content" {
+					t.Errorf("got %q", resp.Content)
+				}
+			}
+
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("got %d server hits, want %d", got, tt.wantHits)
+			}
+			if got := revalidated.Load(); got != tt.wantRevalidated {
+				t.Errorf("got %d revalidations, want %d", got, tt.wantRevalidated)
+			}
+		})
+	}
+}
+
+func TestHTTPCacheMiddlewareVary(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "X-Variant")
+		_, _ = w.Write([]byte(r.Header.Get("X-Variant")))
+	}))
+	defer srv.Close()
+
+	variant := "a"
+	withVariant := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Variant", variant)
+			return next.RoundTrip(req)
+		})
+	}
+	client := NewClient(WithMiddleware(withVariant, HTTPCacheMiddleware(newMemoryCache(), false)))
+
+	for _, v := range []string{"a", "a", "b", "b"} {
+		variant = v
+		resp, err := client.Get(t.Context(), srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(resp.Content) != v {
+			t.Errorf("got variant %q, want %q", resp.Content, v)
+		}
+	}
+
+	// the entry keeps the last variant only
+	if got := hits.Load(); got != 2 {
+		t.Errorf("got %d server hits, want 2", got)
+	}
+}
+
+func TestHTTPCacheMiddlewareConditional(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(WithMiddleware(HTTPCacheMiddleware(newMemoryCache(), false)))
+	if _, err := client.Get(t.Context(), srv.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.Close()
+
+	// the caller's own conditions are checked against the fresh entry
+	resp, err := client.GetIfModified(t.Context(), srv.URL, Validators{ETag: `W/"v1"`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.NotModified() {
+		t.Errorf("got status %d, want 304", resp.StatusCode)
+	}
+
+	resp, err = client.GetIfModified(t.Context(), srv.URL, Validators{ETag: `"v0"`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.NotModified() || string(resp.Content) != "content" {
+		t.Errorf("got status %d, content %q", resp.StatusCode, resp.Content)
+	}
+}
+
+func TestHTTPCacheMiddlewareCookies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Add("Set-Cookie", "session=s3cret")
+		w.Header().Add("Set-Cookie2", "legacy=s3cret")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	cache := newMemoryCache()
+	client := NewClient(WithMiddleware(HTTPCacheMiddleware(cache, false)))
+
+	for range 2 {
+		resp, err := client.Get(t.Context(), srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the live response keeps its cookies
+		if resp.Header.Get("Set-Cookie") != "session=s3cret" {
+			t.Errorf("got Set-Cookie %q", resp.Header.Get("Set-Cookie"))
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	entry, ok := cache.Get(cacheKey(req))
+	if !ok {
+		t.Fatal("response is not cached")
+	}
+	if entry.Header.Get("Set-Cookie") != "" || entry.Header.Get("Set-Cookie2") != "" || entry.Header.Get("ETag") != `"v1"` {
+		t.Errorf("unexpected cached header: %v", entry.Header)
+	}
+}
+
+func TestHTTPCacheMiddlewareCredentials(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		switch r.URL.Path {
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/vary":
+			w.Header().Set("Vary", "X-Api-Key")
+		}
+		_, _ = w.Write([]byte("content for " + r.Header.Get("X-Api-Key") + r.Header.Get("Cookie")))
+	}))
+	defer srv.Close()
+
+	srvURL, _ := urllib.Parse(srv.URL)
+	withCookie := Middleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/cookie" {
+				req = req.Clone(req.Context())
+				req.Header.Set("Cookie", "session=s3cret")
+			}
+			return next.RoundTrip(req)
+		})
+	})
+
+	tests := []struct {
+		path     string
+		wantHits int32
+	}{
+		{"/private", 2},
+		{"/cookie", 2},
+		{"/public", 1},
+		{"/vary", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			hits.Store(0)
+			client := NewClient(WithMiddleware(
+				withCookie,
+				HostAuthMiddleware([]HostAuth{{Host: srvURL.Host, Auth: HeaderAuth{Name: "X-Api-Key", Value: "key"}, AllowHTTP: true}}),
+				HTTPCacheMiddleware(newMemoryCache(), false),
+			))
+
+			for range 2 {
+				if _, err := client.Get(t.Context(), srv.URL+tt.path); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("got %d server hits, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestHTTPCacheMiddlewareOffline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// stale at once, offline mode replays it anyway
+		w.Header().Set("Cache-Control", "max-age=0")
+		_, _ = w.Write([]byte("content " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	cache := NewDiskCache(t.TempDir())
+	online := NewClient(WithMiddleware(HTTPCacheMiddleware(cache, false)))
+	if _, err := online.Get(t.Context(), srv.URL+"/page"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.Close()
+
+	offline := NewClient(WithMiddleware(HTTPCacheMiddleware(cache, true)))
+
+	resp, err := offline.Get(t.Context(), srv.URL+"/page#section")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Content) != "content /page" {
+		t.Errorf("got %q", resp.Content)
+	}
+
+	head, err := offline.Head(t.Context(), srv.URL+"/page")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if head.StatusCode != http.StatusOK {
+		t.Errorf("got HEAD status %d", head.StatusCode)
+	}
+
+	_, err = offline.Get(t.Context(), srv.URL+"/missing")
+	var notCached *NotCachedError
+	if !errors.As(err, &notCached) || !notCached.Permanent() {
+		t.Errorf("got %v, want NotCachedError", err)
+	}
+}
+
+func TestCachedResponseFreshnessLifetime(t *testing.T) {
+	date := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		want   time.Duration
+	}{
+		{"max_age", 200, map[string]string{"Cache-Control": "public, max-age=120", "Expires": date.Add(time.Hour).Format(http.TimeFormat)}, 2 * time.Minute},
+		{"expires", 200, map[string]string{"Expires": date.Add(time.Hour).Format(http.TimeFormat)}, time.Hour},
+		{"invalid_expires", 200, map[string]string{"Expires": "0"}, 0},
+		{"heuristic", 200, map[string]string{"Last-Modified": date.Add(-10 * time.Hour).Format(http.TimeFormat)}, time.Hour},
+		{"heuristic_limit", 200, map[string]string{"Last-Modified": date.AddDate(-1, 0, 0).Format(http.TimeFormat)}, heuristicMaxLifetime},
+		{"heuristic_not_cacheable", 302, map[string]string{"Last-Modified": date.Add(-10 * time.Hour).Format(http.TimeFormat)}, 0},
+		{"none", 200, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &CachedResponse{StatusCode: tt.status, Header: http.Header{"Date": {date.Format(http.TimeFormat)}}}
+			for name, value := range tt.header {
+				resp.Header.Set(name, value)
+			}
+
+			if got := resp.freshnessLifetime(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ratioCheckMinSize - небольшие ответы могут сжиматься очень сильно (например, пустая разметка), это не бомба.
+	ratioCheckMinSize = 1 << 20
+	readChunkSize     = 32 << 10
+)
+
+type LimitKind string
+
+const (
+	LimitBodySize     LimitKind = "body_size"
+	LimitRatio        LimitKind = "decompression_ratio"
+	LimitTransferRate LimitKind = "transfer_rate"
+	LimitHeaderSize   LimitKind = "header_size"
+)
+
+// Limits - ограничения на ответ сервера, нулевое значение - без ограничения.
+type Limits struct {
+	// MaxBodySize - максимальный размер декодированного тела.
+	MaxBodySize int64
+	// MaxRatio - максимальное отношение декодированного размера к полученному.
+	MaxRatio float64
+	// MinRate - минимальная скорость получения тела (байт/с), проверяется за каждое окно RateWindow.
+	MinRate    int64
+	RateWindow time.Duration
+	// MaxHeaderSize - максимальный размер заголовков ответа.
+	MaxHeaderSize int64
+}
+
+// LimitError - ответ нарушил Limits. Повторный запрос получит тот же ответ, поэтому ошибка не повторяется (retry.Permanent).
+type LimitError struct {
+	Kind  LimitKind
+	Limit any
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("response exceeds %s limit %v", e.Kind, e.Limit)
+}
+
+func (e *LimitError) Permanent() bool {
+	return true
+}
+
+// WithLimits задает лимиты ответа. Для общего transport MaxHeaderSize задается в TransportConfig.
+func WithLimits(limits Limits) OptionFunc {
+	return func(f *Client) {
+		f.limits = limits
+	}
+}
+
+// readBody декодирует тело и читает его, проверяя лимиты по мере чтения, а не после.
+// Возвращает декодированное содержимое и тело в том виде, в каком оно пришло.
+func (c *Client) readBody(resp *http.Response) ([]byte, []byte, error) {
+	var body io.ReadCloser = resp.Body
+	if c.limits.MinRate > 0 {
+		rate := newRateGuard(resp.Body, c.limits.MinRate, c.limits.RateWindow)
+		defer rate.stop()
+		body = rate
+	}
+
+	contentEncoding := resp.Header.Get("Content-Encoding")
+
+	var raw bytes.Buffer
+	var encoded *countingReader
+	if isIdentityEncoding(contentEncoding) {
+		encoded = &countingReader{r: body}
+	} else {
+		encoded = &countingReader{r: io.TeeReader(body, &raw)}
+	}
+
+	decoded, err := decodeBody(encoded, contentEncoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer decoded.Close()
+
+	var content []byte
+	buf := make([]byte, readChunkSize)
+	for {
+		n, err := decoded.Read(buf)
+		content = append(content, buf[:n]...)
+
+		if c.limits.MaxBodySize > 0 && int64(len(content)) > c.limits.MaxBodySize {
+			return nil, nil, &LimitError{Kind: LimitBodySize, Limit: c.limits.MaxBodySize}
+		}
+		if c.limits.MaxRatio > 0 && len(content) >= ratioCheckMinSize && float64(len(content)) > c.limits.MaxRatio*float64(encoded.n) {
+			return nil, nil, &LimitError{Kind: LimitRatio, Limit: c.limits.MaxRatio}
+		}
+
+		if err == io.EOF {
+			if raw.Len() == 0 {
+				return content, content, nil
+			}
+			return content, raw.Bytes(), nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// isHeaderLimitErr - transport не экспортирует ошибку превышения MaxResponseHeaderBytes.
+func isHeaderLimitErr(err error) bool {
+	return strings.Contains(err.Error(), "server response headers exceeded")
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// rateGuard закрывает тело, если за окно получено меньше minRate*window байт (slowloris).
+// Проверка идет в отдельной горутине, потому что Read медленного сервера может блокироваться сколь угодно долго.
+type rateGuard struct {
+	body    io.ReadCloser
+	minRate int64
+	read    atomic.Int64
+	failed  atomic.Bool
+	done    chan struct{}
+}
+
+func newRateGuard(body io.ReadCloser, minRate int64, window time.Duration) *rateGuard {
+	if window <= 0 {
+		window = 5 * time.Second
+	}
+
+	g := &rateGuard{body: body, minRate: minRate, done: make(chan struct{})}
+	minBytes := int64(float64(minRate) * window.Seconds())
+
+	go func() {
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+
+		var last int64
+		for {
+			select {
+			case <-g.done:
+				return
+			case <-ticker.C:
+				current := g.read.Load()
+				if current-last < minBytes {
+					g.failed.Store(true)
+					_ = g.body.Close()
+					return
+				}
+				last = current
+			}
+		}
+	}()
+
+	return g
+}
+
+func (g *rateGuard) Read(p []byte) (int, error) {
+	n, err := g.body.Read(p)
+	g.read.Add(int64(n))
+	if err != nil && err != io.EOF && g.failed.Load() {
+		return n, &LimitError{Kind: LimitTransferRate, Limit: g.minRate}
+	}
+	return n, err
+}
+
+func (g *rateGuard) Close() error {
+	g.stop()
+	return g.body.Close()
+}
+
+func (g *rateGuard) stop() {
+	select {
+	case <-g.done:
+	default:
+		close(g.done)
+	}
+}
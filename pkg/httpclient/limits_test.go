@@ -0,0 +1,122 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gzipBytes(t *testing.T, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestClientGetLimits(t *testing.T) {
+	bomb := gzipBytes(t, make([]byte, 16<<20))
+
+	tests := []struct {
+		name    string
+		limits  Limits
+		handler http.HandlerFunc
+		want    LimitKind
+		content string
+	}{
+		{
+			name:   "gzip_is_decoded",
+			limits: Limits{MaxBodySize: 1 << 10},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					t.Errorf("gzip is not accepted: %q", r.Header.Get("Accept-Encoding"))
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = w.Write(gzipBytes(t, []byte("hello")))
+			},
+			content: "hello",
+		},
+		{
+			name:   "decoded_size_is_limited",
+			limits: Limits{MaxBodySize: 1 << 20},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = w.Write(bomb)
+			},
+			want: LimitBodySize,
+		},
+		{
+			name:   "ratio_is_limited",
+			limits: Limits{MaxRatio: 100},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = w.Write(bomb)
+			},
+			want: LimitRatio,
+		},
+		{
+			name:   "slow_body_is_aborted",
+			limits: Limits{MinRate: 1 << 10, RateWindow: 100 * time.Millisecond},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000")
+				for range 10 {
+					_, _ = w.Write([]byte("x"))
+					w.(http.Flusher).Flush()
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(100 * time.Millisecond):
+					}
+				}
+			},
+			want: LimitTransferRate,
+		},
+		{
+			name:   "header_size_is_limited",
+			limits: Limits{MaxHeaderSize: 1 << 10},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Padding", strings.Repeat("x", 4<<10))
+				_, _ = w.Write([]byte("hello"))
+			},
+			want: LimitHeaderSize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			resp, err := NewClient(WithLimits(tt.limits)).Get(t.Context(), srv.URL)
+
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(resp.Content) != tt.content {
+					t.Errorf("got %q, want %q", resp.Content, tt.content)
+				}
+				return
+			}
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("want *LimitError, got %v", err)
+			}
+			if limitErr.Kind != tt.want {
+				t.Errorf("got %s, want %s", limitErr.Kind, tt.want)
+			}
+		})
+	}
+}
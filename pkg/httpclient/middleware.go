@@ -0,0 +1,251 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	urllib "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware оборачивает RoundTripper, чтобы добавить к запросам сквозную логику (логирование, лимиты, авторизация и т.п.).
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc - адаптер функции к http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithMiddleware добавляет middleware, первая добавленная выполняется первой (оборачивает остальные).
+func WithMiddleware(middlewares ...Middleware) OptionFunc {
+	return func(f *Client) {
+		f.middlewares = append(f.middlewares, middlewares...)
+	}
+}
+
+func chain(rt http.RoundTripper, middlewares []Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// LoggingMiddleware пишет в debug каждый запрос: метод, url, статус и время. Заголовки не логируются (в них секреты).
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			startedAt := time.Now()
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				logger.Debug("HTTP request failed", "method", req.Method, "url", req.URL.Redacted(), "elapsed", time.Since(startedAt), "err", err)
+				return nil, err
+			}
+
+			logger.Debug("HTTP request", "method", req.Method, "url", req.URL.Redacted(), "status", resp.StatusCode, "elapsed", time.Since(startedAt))
+			return resp, nil
+		})
+	}
+}
+
+// MetricsRecorder - получатель метрик запросов (например, internal.Metrics).
+type MetricsRecorder interface {
+	RecordResponseTime(duration time.Duration)
+	RecordError(err string)
+}
+
+// MetricsMiddleware учитывает время до получения заголовков ответа и ошибки транспорта.
+func MetricsMiddleware(recorder MetricsRecorder) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			startedAt := time.Now()
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				recorder.RecordError(err.Error())
+				return nil, err
+			}
+
+			recorder.RecordResponseTime(time.Since(startedAt))
+			return resp, nil
+		})
+	}
+}
+
+// RateLimitMiddleware ограничивает частоту запросов к каждому хосту (запросов в секунду), ожидание прерывается контекстом.
+func RateLimitMiddleware(perSecond float64) Middleware {
+	interval := time.Duration(float64(time.Second) / perSecond)
+
+	var mu sync.Mutex
+	nextAt := map[string]time.Time{}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			at := time.Now()
+			if scheduled := nextAt[req.URL.Host]; scheduled.After(at) {
+				at = scheduled
+			}
+			nextAt[req.URL.Host] = at.Add(interval)
+			mu.Unlock()
+
+			if wait := time.Until(at); wait > 0 {
+				timer := time.NewTimer(wait)
+				defer timer.Stop()
+
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-timer.C:
+				}
+			}
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// HeadersMiddleware добавляет заголовки, которые не заданы в самом запросе.
+func HeadersMiddleware(headers http.Header) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// @idiomatic: RoundTripper must not modify the request
+			req = req.Clone(req.Context())
+			for name, values := range headers {
+				if req.Header.Get(name) == "" {
+					req.Header[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Authenticator добавляет к запросу учетные данные.
+type Authenticator interface {
+	Authenticate(req *http.Request)
+}
+
+type BasicAuth struct {
+	Username string
+	Password Secret
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) {
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password.Reveal())))
+}
+
+type BearerAuth struct {
+	Token Secret
+}
+
+func (a BearerAuth) Authenticate(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.Token.Reveal())
+}
+
+// HeaderAuth передает учетные данные произвольным заголовком (например, X-Api-Key).
+type HeaderAuth struct {
+	Name  string
+	Value Secret
+}
+
+func (a HeaderAuth) Authenticate(req *http.Request) {
+	req.Header.Set(a.Name, a.Value.Reveal())
+}
+
+// HostAuth - учетные данные для хоста: "wiki.example.com", "wiki.example.com:8443" (только этот порт) или "*.example.com" (поддомены).
+// Учетные данные отправляются только по https, даже после редиректа на http того же хоста, если не задан AllowHTTP.
+type HostAuth struct {
+	Host string
+	Auth Authenticator
+	// AllowHTTP - отправлять учетные данные и по http (например, хост во внутренней сети без tls).
+	AllowHTTP bool
+}
+
+func (h HostAuth) Matches(u *urllib.URL) bool {
+	if !strings.EqualFold(u.Scheme, "https") && !h.AllowHTTP {
+		return false
+	}
+
+	host := strings.ToLower(h.Host)
+	if strings.Contains(host, ":") {
+		return host == strings.ToLower(u.Host)
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	if suffix, ok := strings.CutPrefix(host, "*."); ok {
+		return strings.HasSuffix(hostname, "."+suffix)
+	}
+	return hostname == host
+}
+
+// HostAuthMiddleware авторизует запрос первым подходящим правилом.
+// Middleware вызывается для каждого запроса цепочки редиректов отдельно, поэтому после редиректа
+// на другой хост учетные данные исходного хоста не передаются.
+func HostAuthMiddleware(rules []HostAuth) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for _, rule := range rules {
+				if rule.Matches(req.URL) {
+					authenticated := req.Clone(req.Context())
+					rule.Auth.Authenticate(authenticated)
+					req = authenticated.WithContext(context.WithValue(authenticated.Context(), authHeadersKey{}, changedHeaders(req.Header, authenticated.Header)))
+					break
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// authHeadersKey - заголовки, в которые HostAuthMiddleware положил учетные данные (см. credentialHeaders).
+type authHeadersKey struct{}
+
+func changedHeaders(before, after http.Header) []string {
+	var names []string
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Cache - хранилище ответов для HTTPCacheMiddleware.
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+}
+
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// RequestHeader - значения заголовков запроса, перечисленных в Vary.
+	RequestHeader http.Header `json:",omitempty"`
+	RequestTime   time.Time   `json:",omitzero"`
+	ResponseTime  time.Time   `json:",omitzero"`
+}
+
+func (c *CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(c.StatusCode),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,192 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	urllib "net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMiddlewareOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var calls []string
+	track := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := NewClient(WithMiddleware(track("first"), track("second")), WithMiddleware(track("third")))
+	if _, err := client.Get(t.Context(), srv.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"first", "second", "third"}; !slices.Equal(calls, want) {
+		t.Errorf("got %v, want %v", calls, want)
+	}
+}
+
+func TestHeadersAndAuthMiddleware(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	srvURL, _ := urllib.Parse(srv.URL)
+	client := NewClient(WithMiddleware(
+		HeadersMiddleware(http.Header{"X-Team": {"crawler"}, "User-Agent": {"ignored"}}),
+		HostAuthMiddleware([]HostAuth{{Host: srvURL.Host, Auth: BearerAuth{Token: Secret("secret")}, AllowHTTP: true}}),
+	))
+	if _, err := client.Get(t.Context(), srv.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.Get("X-Team") != "crawler" {
+		t.Errorf("X-Team = %q", got.Get("X-Team"))
+	}
+	if got.Get("User-Agent") != defaultUserAgent {
+		t.Errorf("request header was overridden: %q", got.Get("User-Agent"))
+	}
+	if got.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Authorization = %q", got.Get("Authorization"))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := NewClient(WithMiddleware(RateLimitMiddleware(20)))
+
+	startedAt := time.Now()
+	for range 4 {
+		if _, err := client.Get(t.Context(), srv.URL); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// first request is not delayed, the next three wait 50ms each
+	if elapsed := time.Since(startedAt); elapsed < 150*time.Millisecond {
+		t.Errorf("requests were not limited, elapsed %v", elapsed)
+	}
+}
+
+type testRecorder struct {
+	responses atomic.Int32
+	errors    atomic.Int32
+}
+
+func (r *testRecorder) RecordResponseTime(time.Duration) { r.responses.Add(1) }
+func (r *testRecorder) RecordError(string)               { r.errors.Add(1) }
+
+func TestMetricsMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := &testRecorder{}
+	client := NewClient(WithMiddleware(MetricsMiddleware(recorder)))
+
+	if _, err := client.Get(t.Context(), srv.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	srv.Close()
+	if _, err := client.Get(t.Context(), srv.URL); err == nil {
+		t.Fatal("want error")
+	}
+
+	if recorder.responses.Load() != 1 || recorder.errors.Load() != 1 {
+		t.Errorf("got %d responses, %d errors", recorder.responses.Load(), recorder.errors.Load())
+	}
+}
+
+func TestHostAuthMiddleware(t *testing.T) {
+	var otherAuth, otherKey string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherAuth, otherKey = r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")
+	}))
+	defer other.Close()
+
+	var wikiAuth string
+	wiki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wikiAuth = r.Header.Get("Authorization")
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	}))
+	defer wiki.Close()
+
+	wikiURL, _ := urllib.Parse(wiki.URL)
+
+	client := NewClient(WithMiddleware(HostAuthMiddleware([]HostAuth{
+		{Host: wikiURL.Host, Auth: BasicAuth{Username: "crawler", Password: "pa55"}, AllowHTTP: true},
+		{Host: "*.example.com", Auth: HeaderAuth{Name: "X-Api-Key", Value: "key"}, AllowHTTP: true},
+	})))
+
+	if _, err := client.Get(t.Context(), wiki.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if wikiAuth != "Basic Y3Jhd2xlcjpwYTU1" {
+		t.Errorf("wiki Authorization = %q", wikiAuth)
+	}
+	if otherAuth != "" || otherKey != "" {
+		t.Errorf("credentials leaked after redirect: %q, %q", otherAuth, otherKey)
+	}
+}
+
+func TestHostAuthMatches(t *testing.T) {
+	tests := []struct {
+		host      string
+		url       string
+		allowHTTP bool
+		want      bool
+	}{
+		{"wiki.example.com", "https://wiki.example.com/page", false, true},
+		{"wiki.example.com", "https://WIKI.example.com:8443/page", false, true},
+		{"wiki.example.com", "https://evil.com/?wiki.example.com", false, false},
+		{"wiki.example.com", "https://wiki.example.com.evil.com/", false, false},
+		{"wiki.example.com:8443", "https://wiki.example.com/", false, false},
+		{"wiki.example.com:8443", "https://wiki.example.com:8443/", false, true},
+		{"*.example.com", "https://docs.example.com/", false, true},
+		{"*.example.com", "https://example.com/", false, false},
+		{"*.example.com", "https://notexample.com/", false, false},
+		{"wiki.example.com", "http://wiki.example.com/", false, false},
+		{"wiki.example.com", "http://wiki.example.com/", true, true},
+		{"wiki.example.com", "HTTPS://wiki.example.com/", false, true},
+	}
+
+	for _, tt := range tests {
+		u, _ := urllib.Parse(tt.url)
+		if got := (HostAuth{Host: tt.host, AllowHTTP: tt.allowHTTP}).Matches(u); got != tt.want {
+			t.Errorf("%s (allow http %v) matches %s = %v, want %v", tt.host, tt.allowHTTP, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSecretIsRedacted(t *testing.T) {
+	auth := BasicAuth{Username: "crawler", Password: "pa55"}
+
+	var logged bytes.Buffer
+	slog.New(slog.NewTextHandler(&logged, nil)).Info("auth", "password", auth.Password, "auth", auth)
+	marshaled, _ := json.Marshal(auth)
+
+	for _, out := range []string{fmt.Sprintf("%v %+v %#v %s", auth, auth, auth, auth.Password), logged.String(), string(marshaled)} {
+		if strings.Contains(out, "pa55") {
+			t.Errorf("secret is revealed: %s", out)
+		}
+	}
+}
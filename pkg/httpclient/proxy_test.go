@@ -0,0 +1,393 @@
+package httpclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	urllib "net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// httpProxy - прокси-заглушка: пересылает запросы с абсолютным url и туннелирует CONNECT.
+type httpProxy struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	auth     []string
+}
+
+func newHTTPProxy(t *testing.T) *httpProxy {
+	t.Helper()
+
+	p := &httpProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests = append(p.requests, r.Method+" "+r.Host)
+		p.auth = append(p.auth, r.Header.Get("Proxy-Authorization"))
+		p.mu.Unlock()
+
+		if r.Method == http.MethodConnect {
+			target, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			pipe(conn, target)
+			return
+		}
+
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		for name, values := range resp.Header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *httpProxy) seen() ([]string, []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...), append([]string(nil), p.auth...)
+}
+
+// newSOCKS5Proxy - SOCKS5-заглушка (RFC 1928) с авторизацией по логину и паролю (RFC 1929), только CONNECT.
+func newSOCKS5Proxy(t *testing.T, username, password string) (string, *atomic.Int32) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	var connects atomic.Int32
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				target, err := socks5Handshake(conn, username, password)
+				if err != nil {
+					_ = conn.Close()
+					return
+				}
+				connects.Add(1)
+				pipe(conn, target)
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), &connects
+}
+
+func socks5Handshake(conn net.Conn, username, password string) (net.Conn, error) {
+	buf := make([]byte, 512)
+
+	// greeting: version, methods
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return nil, err
+	}
+	_, _ = conn.Write([]byte{5, 2})
+
+	// username/password auth
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	user := make([]byte, buf[1])
+	_, _ = io.ReadFull(conn, user)
+	_, _ = io.ReadFull(conn, buf[:1])
+	pass := make([]byte, buf[0])
+	_, _ = io.ReadFull(conn, pass)
+	if string(user) != username || string(pass) != password {
+		_, _ = conn.Write([]byte{1, 1})
+		return nil, errors.New("invalid credentials")
+	}
+	_, _ = conn.Write([]byte{1, 0})
+
+	// request: version, cmd, reserved, address type
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return nil, err
+	}
+
+	var host string
+	switch buf[3] {
+	case 1:
+		_, _ = io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 3:
+		_, _ = io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		_, _ = io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return nil, errors.New("unsupported address type")
+	}
+	_, _ = io.ReadFull(conn, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	return target, nil
+}
+
+func pipe(a, b net.Conn) {
+	go func() {
+		_, _ = io.Copy(a, b)
+		_ = a.Close()
+	}()
+	_, _ = io.Copy(b, a)
+	_ = b.Close()
+}
+
+func newProxiedClient(t *testing.T, router *ProxyRouter, guard *DialGuard) (*Client, *http.Transport) {
+	t.Helper()
+
+	cfg := DefaultTransportConfig()
+	cfg.Proxy = router
+	cfg.DialGuard = guard
+
+	transport := NewTransport(cfg)
+	t.Cleanup(transport.CloseIdleConnections)
+
+	return NewClient(WithTransport(transport)), transport
+}
+
+func TestProxyHTTP(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	targetURL, _ := urllib.Parse(target.URL)
+
+	proxy := newHTTPProxy(t)
+	proxyURL, _ := urllib.Parse(proxy.URL)
+	proxyURL.User = urllib.UserPassword("crawler", "pa55")
+
+	router, err := NewProxyRouter(proxyURL.String(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the proxy itself is on loopback too, but the destination is checked before the request is sent to it
+	blockedClient, _ := newProxiedClient(t, router, NewDialGuard(false, nil, nil))
+
+	var blocked *BlockedAddressError
+	if _, err := blockedClient.Get(t.Context(), target.URL); !errors.As(err, &blocked) {
+		t.Fatalf("got %v, want BlockedAddressError", err)
+	}
+	if requests, _ := proxy.seen(); len(requests) != 0 {
+		t.Fatalf("blocked request reached the proxy: %v", requests)
+	}
+
+	client, _ := newProxiedClient(t, router, NewDialGuard(false, nil, []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}))
+
+	resp, err := client.Get(t.Context(), target.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Content) != "ok" {
+		t.Errorf("got %q", resp.Content)
+	}
+
+	requests, auth := proxy.seen()
+	if len(requests) != 1 || requests[0] != "GET "+targetURL.Host {
+		t.Errorf("got proxy requests %v", requests)
+	}
+	if auth[0] != "Basic Y3Jhd2xlcjpwYTU1" {
+		t.Errorf("got Proxy-Authorization %q", auth[0])
+	}
+}
+
+func TestProxyRedirectToProxyAddress(t *testing.T) {
+	proxy := newHTTPProxy(t)
+
+	// the target is on another loopback address, so the guard allows it but not the proxy
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 is not available: %v", err)
+	}
+	target := httptest.NewUnstartedServer(http.RedirectHandler(proxy.URL+"/", http.StatusFound))
+	target.Listener = ln
+	target.Start()
+	defer target.Close()
+
+	// the redirect to the proxy address is connected to directly
+	router, err := NewProxyRouter(proxy.URL, "127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ := newProxiedClient(t, router, NewDialGuard(false, nil, []netip.Prefix{netip.MustParsePrefix("127.0.0.2/32")}))
+
+	var blocked *BlockedAddressError
+	if _, err := client.Get(t.Context(), target.URL); !errors.As(err, &blocked) {
+		t.Fatalf("got %v, want BlockedAddressError", err)
+	}
+
+	// only the first request went through the proxy
+	targetURL, _ := urllib.Parse(target.URL)
+	if requests, _ := proxy.seen(); len(requests) != 1 || requests[0] != "GET "+targetURL.Host {
+		t.Errorf("got proxy requests %v", requests)
+	}
+}
+
+func TestProxyHTTPSConnect(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure"))
+	}))
+	defer target.Close()
+	targetURL, _ := urllib.Parse(target.URL)
+
+	proxy := newHTTPProxy(t)
+
+	router, _ := NewProxyRouter(proxy.URL, "", nil)
+	client, transport := newProxiedClient(t, router, nil)
+	transport.TLSClientConfig = target.Client().Transport.(*http.Transport).TLSClientConfig
+
+	resp, err := client.Get(t.Context(), target.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Content) != "secure" {
+		t.Errorf("got %q", resp.Content)
+	}
+
+	if requests, _ := proxy.seen(); len(requests) != 1 || requests[0] != "CONNECT "+targetURL.Host {
+		t.Errorf("got proxy requests %v", requests)
+	}
+}
+
+func TestProxySOCKS5(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	addr, connects := newSOCKS5Proxy(t, "crawler", "pa55")
+
+	t.Run("authenticated", func(t *testing.T) {
+		router, _ := NewProxyRouter("socks5://crawler:pa55@"+addr, "", nil)
+		client, _ := newProxiedClient(t, router, NewDialGuard(false, nil, []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}))
+
+		resp, err := client.Get(t.Context(), target.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(resp.Content) != "ok" || connects.Load() != 1 {
+			t.Errorf("got %q, %d connects", resp.Content, connects.Load())
+		}
+	})
+
+	t.Run("wrong_password", func(t *testing.T) {
+		router, _ := NewProxyRouter("socks5://crawler:wrong@"+addr, "", nil)
+		client, _ := newProxiedClient(t, router, nil)
+
+		if _, err := client.Get(t.Context(), target.URL); err == nil {
+			t.Fatal("want error")
+		}
+	})
+}
+
+func TestProxyRouterRules(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	targetURL, _ := urllib.Parse(target.URL)
+
+	defaultProxy := newHTTPProxy(t)
+	docsProxy := newHTTPProxy(t)
+
+	router, err := NewProxyRouter(defaultProxy.URL, "internal.example.com, 10.0.0.0/8", []ProxyRule{
+		{Hosts: []string{"docs.example.com", targetURL.Host}, Proxy: docsProxy.URL},
+		{Hosts: []string{".direct.example.com", "*.onion"}, Proxy: ProxyDirect},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://example.com/", defaultProxy.URL},
+		{"http://docs.example.com/", docsProxy.URL},
+		{"http://api.docs.example.com/", docsProxy.URL},
+		{"http://a.direct.example.com/", ""},
+		{"http://site.onion/", ""},
+		{"http://internal.example.com/", ""},
+		{"http://wiki.internal.example.com/", ""},
+		{"http://notinternal.example.com/", defaultProxy.URL},
+		{"http://10.1.2.3/", ""},
+		{"http://11.1.2.3/", defaultProxy.URL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			got, err := router.Proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotURL := ""
+			if got != nil {
+				gotURL = got.String()
+			}
+			if gotURL != tt.want {
+				t.Errorf("got %q, want %q", gotURL, tt.want)
+			}
+		})
+	}
+
+	client, _ := newProxiedClient(t, router, nil)
+	if _, err := client.Get(t.Context(), target.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests, _ := docsProxy.seen(); len(requests) != 1 {
+		t.Errorf("request is not routed by the rule: %v", requests)
+	}
+	if requests, _ := defaultProxy.seen(); len(requests) != 0 {
+		t.Errorf("request is routed to the default proxy: %v", requests)
+	}
+}
+
+func TestNewProxyRouterInvalid(t *testing.T) {
+	for _, proxy := range []string{"ftp://proxy:21", "proxy:3128", "http://"} {
+		if _, err := NewProxyRouter(proxy, "", nil); err == nil {
+			t.Errorf("%s: want error", proxy)
+		}
+	}
+	if _, err := NewProxyRouter("", "", []ProxyRule{{Proxy: ProxyDirect}}); err == nil {
+		t.Error("rule without hosts: want error")
+	}
+}
@@ -0,0 +1,188 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TLSConfig - настройки TLS поверх системных.
+type TLSConfig struct {
+	// RootCAs - nil для системных корневых сертификатов, см. LoadCertPool.
+	RootCAs *x509.CertPool
+	// MinVersion - 0 для значения по умолчанию (tls.VersionTLS12).
+	MinVersion uint16
+	// Hosts - настройки отдельных хостов, применяется первая подходящая.
+	Hosts []TLSHost
+}
+
+// TLSHost - настройки TLS хоста: "wiki.example.com" или "*.example.com" (порт не учитывается).
+type TLSHost struct {
+	Host string
+	// ClientCert - сертификат для mTLS, nil без него.
+	ClientCert *tls.Certificate
+	// InsecureSkipVerify отключает проверку сертификата сервера, только для этого хоста.
+	InsecureSkipVerify bool
+	// Pins - допустимые ключи цепочки сертификатов в формате SPKIPin, пустой - без проверки.
+	Pins []string
+}
+
+// TLSInfo - параметры TLS-соединения, по которому получен ответ.
+type TLSInfo struct {
+	Version     string
+	CipherSuite string
+	ServerName  string
+	// Chain - сертификаты сервера, начиная с сертификата самого сервера.
+	Chain []CertificateInfo
+}
+
+type CertificateInfo struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+}
+
+func (h TLSHost) Matches(hostname string) bool {
+	host := strings.ToLower(h.Host)
+	hostname = strings.ToLower(hostname)
+	if suffix, ok := strings.CutPrefix(host, "*."); ok {
+		return strings.HasSuffix(hostname, "."+suffix)
+	}
+	return hostname == host
+}
+
+// LoadCertPool добавляет к системным корневым сертификатам сертификаты из PEM-файлов (например, внутреннего CA).
+func LoadCertPool(paths ...string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %q", path)
+		}
+	}
+
+	return pool, nil
+}
+
+// SPKIPin - "sha256/<base64>" от SubjectPublicKeyInfo сертификата, как у curl --pinnedpubkey и HPKP.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// clientConfig - настройки TLS хоста, nil host - для остальных хостов.
+func (c *TLSConfig) clientConfig(host *TLSHost) *tls.Config {
+	cfg := &tls.Config{
+		RootCAs:    c.RootCAs,
+		MinVersion: c.MinVersion,
+	}
+	if host == nil {
+		return cfg
+	}
+
+	cfg.InsecureSkipVerify = host.InsecureSkipVerify
+	if host.ClientCert != nil {
+		// sent even if the server doesn't list its issuer among acceptable CAs
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return host.ClientCert, nil
+		}
+	}
+	if len(host.Pins) > 0 {
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, host.Pins, host.InsecureSkipVerify)
+		}
+	}
+
+	return cfg
+}
+
+// verifyPins ищет ключ в проверенных цепочках: сервер может прислать в PeerCertificates любые лишние сертификаты.
+// Без проверки цепочки (InsecureSkipVerify) сверяется только сертификат самого сервера.
+func verifyPins(cs tls.ConnectionState, pins []string, insecure bool) error {
+	var chains [][]*x509.Certificate
+	switch {
+	case len(cs.VerifiedChains) > 0:
+		chains = cs.VerifiedChains
+	case insecure && len(cs.PeerCertificates) > 0:
+		chains = [][]*x509.Certificate{cs.PeerCertificates[:1]}
+	}
+
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if slices.Contains(pins, SPKIPin(cert)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("tls: no pinned public key in the certificate chain of %s", cs.ServerName)
+}
+
+// tlsHostRouter направляет https-запросы к хостам с отдельными настройками TLS в их собственные transport:
+// tls.Config один на transport, а в его callback'ах хост запроса неизвестен. Остальные запросы
+// (ErrSkipAltProtocol) выполняет основной transport.
+type tlsHostRouter struct {
+	config     *TLSConfig
+	transports []*http.Transport
+}
+
+func newTLSHostRouter(cfg TransportConfig) *tlsHostRouter {
+	router := &tlsHostRouter{config: cfg.TLS}
+	for i := range cfg.TLS.Hosts {
+		router.transports = append(router.transports, newTransport(cfg, cfg.TLS.clientConfig(&cfg.TLS.Hosts[i])))
+	}
+	return router
+}
+
+func (r *tlsHostRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	for i := range r.config.Hosts {
+		if r.config.Hosts[i].Matches(req.URL.Hostname()) {
+			return r.transports[i].RoundTrip(req)
+		}
+	}
+	return nil, http.ErrSkipAltProtocol
+}
+
+func newTLSInfo(cs *tls.ConnectionState) *TLSInfo {
+	if cs == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ServerName:  cs.ServerName,
+	}
+	for _, cert := range cs.PeerCertificates {
+		info.Chain = append(info.Chain, CertificateInfo{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			NotAfter: cert.NotAfter,
+		})
+	}
+	return info
+}
+
+// ExpiresAt - когда истекает первый из сертификатов цепочки.
+func (i *TLSInfo) ExpiresAt() time.Time {
+	var res time.Time
+	for _, cert := range i.Chain {
+		if res.IsZero() || cert.NotAfter.Before(res) {
+			res = cert.NotAfter
+		}
+	}
+	return res
+}
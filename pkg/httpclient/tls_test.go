@@ -0,0 +1,182 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTLSClient(t *testing.T, cfg *TLSConfig) *Client {
+	t.Helper()
+
+	transportConfig := DefaultTransportConfig()
+	transportConfig.TLS = cfg
+	transport := NewTransport(transportConfig)
+	t.Cleanup(transport.CloseIdleConnections)
+
+	return NewClient(WithTransport(transport))
+}
+
+func serverPool(srv *httptest.Server) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return pool
+}
+
+// newClientCert создает самоподписанный сертификат клиента для mTLS.
+func newClientCert(t *testing.T) (*tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "crawler"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+func TestTLSRootCAs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if _, err := newTLSClient(t, &TLSConfig{}).Get(t.Context(), srv.URL); err == nil {
+		t.Fatal("want an error for an unknown CA")
+	}
+
+	resp, err := newTLSClient(t, &TLSConfig{RootCAs: serverPool(srv), MinVersion: tls.VersionTLS13}).Get(t.Context(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.TLS == nil || resp.TLS.Version != "TLS 1.3" || resp.TLS.CipherSuite == "" {
+		t.Fatalf("unexpected TLS info: %+v", resp.TLS)
+	}
+	if len(resp.TLS.Chain) != 1 || !resp.TLS.ExpiresAt().Equal(srv.Certificate().NotAfter) {
+		t.Errorf("unexpected chain: %+v", resp.TLS.Chain)
+	}
+}
+
+func TestTLSInsecureSkipVerifyPerHost(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := newTLSClient(t, &TLSConfig{Hosts: []TLSHost{{Host: "127.0.0.1", InsecureSkipVerify: true}}})
+	if _, err := client.Get(t.Context(), srv.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// other hosts are still verified
+	client = newTLSClient(t, &TLSConfig{Hosts: []TLSHost{{Host: "*.example.com", InsecureSkipVerify: true}}})
+	if _, err := client.Get(t.Context(), srv.URL); err == nil {
+		t.Fatal("want a verification error")
+	}
+}
+
+func TestTLSPins(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, otherCert := newClientCert(t)
+
+	tests := []struct {
+		name    string
+		pin     string
+		wantErr bool
+	}{
+		{"pinned key", SPKIPin(srv.Certificate()), false},
+		{"other key", SPKIPin(otherCert), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTLSClient(t, &TLSConfig{
+				RootCAs: serverPool(srv),
+				Hosts:   []TLSHost{{Host: "127.0.0.1", Pins: []string{tt.pin}}},
+			})
+
+			_, err := client.Get(t.Context(), srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSPinsUnverifiedCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// the pinned certificate is sent by the server, but it isn't a part of the verified chain
+	pinned, pinnedLeaf := newClientCert(t)
+	leaf := &srv.TLS.Certificates[0]
+	leaf.Certificate = append(leaf.Certificate, pinned.Certificate[0])
+
+	hosts := []TLSHost{
+		{Host: "127.0.0.1", Pins: []string{SPKIPin(pinnedLeaf)}},
+		{Host: "127.0.0.1", Pins: []string{SPKIPin(pinnedLeaf)}, InsecureSkipVerify: true},
+	}
+	for _, host := range hosts {
+		client := newTLSClient(t, &TLSConfig{RootCAs: serverPool(srv), Hosts: []TLSHost{host}})
+		if _, err := client.Get(t.Context(), srv.URL); err == nil {
+			t.Errorf("insecure %t: want a pin error for an extra certificate", host.InsecureSkipVerify)
+		}
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	clientCert, clientLeaf := newClientCert(t)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientLeaf)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := newTLSClient(t, &TLSConfig{
+		RootCAs: serverPool(srv),
+		Hosts:   []TLSHost{{Host: "127.0.0.1", ClientCert: clientCert}},
+	})
+	resp, err := client.Get(t.Context(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Content) != "crawler" {
+		t.Errorf("got %q, want the client certificate name", resp.Content)
+	}
+
+	// the certificate is not sent to other hosts
+	client = newTLSClient(t, &TLSConfig{
+		RootCAs: serverPool(srv),
+		Hosts:   []TLSHost{{Host: "wiki.example.com", ClientCert: clientCert}},
+	})
+	if _, err := client.Get(t.Context(), srv.URL); err == nil {
+		t.Fatal("want an error without a client certificate")
+	}
+}
@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/gallyamow/go-crawler/pkg/dnscache"
+	"net"
+	"net/http"
+	"net/netip"
+	urllib "net/url"
+	"sync"
+	"time"
+)
+
+// TransportConfig - настройки соединений, общие для всех запросов краулера.
+type TransportConfig struct {
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost - 0 без ограничения.
+	MaxConnsPerHost int
+
+	MaxHeaderSize int64
+	DisableHTTP2  bool
+
+	// DialGuard - nil без проверки адресов.
+	DialGuard *DialGuard
+	// Proxy - nil для прокси из окружения (HTTP_PROXY, HTTPS_PROXY, NO_PROXY).
+	Proxy *ProxyRouter
+	// TLS - nil для системных настроек.
+	TLS *TLSConfig
+	// Resolver - nil для системного DNS при каждом соединении.
+	Resolver *dnscache.Resolver
+}
+
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		DialTimeout:           10 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+	}
+}
+
+// NewTransport создает transport, который следует разделять между всеми клиентами (WithTransport):
+// только тогда соединения переиспользуются, а MaxConnsPerHost ограничивает нагрузку на хост.
+func NewTransport(cfg TransportConfig) *http.Transport {
+	if cfg.TLS == nil {
+		return newTransport(cfg, nil)
+	}
+
+	transport := newTransport(cfg, cfg.TLS.clientConfig(nil))
+	if len(cfg.TLS.Hosts) > 0 {
+		transport.RegisterProtocol("https", newTLSHostRouter(cfg))
+	}
+	return transport
+}
+
+func newTransport(cfg TransportConfig, tlsConfig *tls.Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	dialContext := dialer.DialContext
+	if cfg.DialGuard != nil {
+		dialContext = cfg.DialGuard.Dialer(cfg.DialTimeout, cfg.KeepAlive).DialContext
+	}
+	unguardedDialContext := dialer.DialContext
+	if cfg.Resolver != nil {
+		// the guard checks addresses after the resolver, overrides included
+		dialContext = cfg.Resolver.DialContext(dialContext)
+		unguardedDialContext = cfg.Resolver.DialContext(unguardedDialContext)
+	}
+
+	// proxies from the environment are checked too, otherwise HTTP_PROXY silently disables the guard
+	proxy := guardProxy(http.ProxyFromEnvironment, cfg.DialGuard, cfg.Resolver)
+	if cfg.Proxy != nil {
+		proxy = recordProxyDial(guardProxy(cfg.Proxy.Proxy, cfg.DialGuard, cfg.Resolver))
+
+		// only the connection to the proxy chosen for the request is not guarded, see proxyDial
+		guardedDialContext := dialContext
+		dialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if pd, ok := ctx.Value(proxyDialKey{}).(*proxyDial); ok && pd.get() == addr {
+				return unguardedDialContext(ctx, network, addr)
+			}
+			return guardedDialContext(ctx, network, addr)
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                  proxy,
+		DialContext:            dialContext,
+		TLSHandshakeTimeout:    cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout:  cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:        cfg.IdleConnTimeout,
+		MaxIdleConns:           cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:    cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:        cfg.MaxConnsPerHost,
+		MaxResponseHeaderBytes: cfg.MaxHeaderSize,
+		TLSClientConfig:        tlsConfig,
+		ExpectContinueTimeout:  1 * time.Second,
+		// custom DialContext disables HTTP/2 unless it is forced
+		ForceAttemptHTTP2: !cfg.DisableHTTP2,
+	}
+
+	if cfg.DisableHTTP2 {
+		// non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport
+}
+
+// guardProxy проверяет адрес назначения запросов, которые идут через прокси: с ним соединяется сам прокси,
+// поэтому dialer DialGuard видит только адрес прокси. Хост разрешается тем же DNS (Resolver, в т.ч. overrides),
+// и если его адреса не разрешить, запрос не отправляется: иначе любой хост, который знает только прокси, пропускался бы.
+// Прокси может разрешить хост по-своему (split-horizon DNS), полную гарантию дает только прямое соединение.
+func guardProxy(proxy func(*http.Request) (*urllib.URL, error), guard *DialGuard, resolver *dnscache.Resolver) func(*http.Request) (*urllib.URL, error) {
+	if guard == nil || len(guard.Deny) == 0 {
+		return proxy
+	}
+
+	return func(req *http.Request) (*urllib.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+
+		address := net.JoinHostPort(req.URL.Hostname(), urlPort(req.URL))
+		var addrs []netip.Addr
+		if resolver != nil {
+			addrs, err = resolver.LookupAddress(req.Context(), address)
+		} else if addr, parseErr := netip.ParseAddr(req.URL.Hostname()); parseErr == nil {
+			addrs = []netip.Addr{addr}
+		} else {
+			addrs, err = net.DefaultResolver.LookupNetIP(req.Context(), "ip", req.URL.Hostname())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("resolve proxied host %s: %w", req.URL.Hostname(), err)
+		}
+
+		for _, addr := range addrs {
+			if err := guard.Check(net.JoinHostPort(addr.Unmap().String(), urlPort(req.URL))); err != nil {
+				return nil, err
+			}
+		}
+		return proxyURL, nil
+	}
+}
+
+// WithTransport задает общий transport. Он уже настроен (NewTransport), поэтому WithDialGuard и
+// Limits.MaxHeaderSize на него не влияют.
+func WithTransport(transport *http.Transport) OptionFunc {
+	return func(f *Client) {
+		f.transport = transport
+	}
+}
+
+type proxyDialKey struct{}
+
+// proxyDial - адрес прокси, выбранного для запроса. DialGuard не проверяет только соединение с ним:
+// прямое соединение с тем же адресом (правило direct, NO_PROXY, редирект на адрес прокси) проверяется.
+// Свой для каждого запроса (withProxyDial), без него проверяются и соединения с прокси.
+type proxyDial struct {
+	mu   sync.Mutex
+	addr string
+}
+
+func (pd *proxyDial) set(addr string) {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	pd.addr = addr
+}
+
+func (pd *proxyDial) get() string {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	return pd.addr
+}
+
+// withProxyDial добавляет в контекст каждого запроса, в т.ч. каждого редиректа, пустой proxyDial.
+func withProxyDial(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return next.RoundTrip(req.WithContext(context.WithValue(req.Context(), proxyDialKey{}, &proxyDial{})))
+	})
+}
+
+// recordProxyDial запоминает в proxyDial запроса адрес выбранного прокси.
+func recordProxyDial(proxy func(*http.Request) (*urllib.URL, error)) func(*http.Request) (*urllib.URL, error) {
+	return func(req *http.Request) (*urllib.URL, error) {
+		proxyURL, err := proxy(req)
+
+		if pd, ok := req.Context().Value(proxyDialKey{}).(*proxyDial); ok {
+			addr := ""
+			if err == nil && proxyURL != nil {
+				addr = proxyEndpoint(proxyURL)
+			}
+			pd.set(addr)
+		}
+		return proxyURL, err
+	}
+}
@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"errors"
+	"github.com/gallyamow/go-crawler/pkg/dnscache"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSharedTransportReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	transport := NewTransport(DefaultTransportConfig())
+	defer transport.CloseIdleConnections()
+
+	for range 3 {
+		client := NewClient(WithTransport(transport))
+		if _, err := client.Get(t.Context(), srv.URL); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Errorf("got %d connections, want 1", got)
+	}
+}
+
+func TestClientBodyTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client := NewClient(WithBodyTimeout(100 * time.Millisecond))
+
+	_, err := client.Get(t.Context(), srv.URL)
+	if !errors.Is(err, ErrBodyTimeout) {
+		t.Fatalf("want ErrBodyTimeout, got %v", err)
+	}
+}
+
+func TestTransportResponseHeaderTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	cfg := DefaultTransportConfig()
+	cfg.ResponseHeaderTimeout = 100 * time.Millisecond
+
+	client := NewClient(WithTransport(NewTransport(cfg)))
+
+	started := time.Now()
+	if _, err := client.Get(t.Context(), srv.URL); err == nil {
+		t.Fatal("want error")
+	}
+	if elapsed := time.Since(started); elapsed > 900*time.Millisecond {
+		t.Errorf("timeout was not applied, elapsed %v", elapsed)
+	}
+}
+
+func TestTransportResolverOverride(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	hostPort, addrs, err := dnscache.ParseOverride("www.example.com:" + port + ":127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := dnscache.New(time.Minute, dnscache.WithOverrides(map[string][]netip.Addr{hostPort: addrs}))
+
+	get := func(guard *DialGuard) (*Response, error) {
+		cfg := DefaultTransportConfig()
+		cfg.Resolver = resolver
+		cfg.DialGuard = guard
+		return NewClient(WithTransport(NewTransport(cfg))).Get(t.Context(), "http://"+hostPort+"/")
+	}
+
+	resp, err := get(NewDialGuard(true, nil, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.Content) != hostPort {
+		t.Errorf("got Host %q, want %q", resp.Content, hostPort)
+	}
+
+	// overridden addresses are still checked by the guard
+	var blocked *BlockedAddressError
+	if _, err := get(NewDialGuard(false, nil, nil)); !errors.As(err, &blocked) {
+		t.Fatalf("want BlockedAddressError, got %v", err)
+	}
+}
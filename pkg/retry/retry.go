@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"math"
 	"math/rand"
 	"time"
@@ -18,6 +19,7 @@ import (
 //     MaxDelay - максимально возможная задержка
 //     JitterFactor - коэффициент до которого может случайным образом увеличиваться delay (0 - если не увеличивать, больше 0 если требуется)
 //     RetryableChecker - функция принимающая ошибку и возвращающая true в случае если нужен повторный вызов, иначе false
+//   - не повторяет ошибки, реализующие Permanent, независимо от RetryableChecker
 //   - реагирует на отмену через контекст
 //   - функция вызывается хотя бы 1 раз независимо от RetryableChecker
 func Retry[T any](ctx context.Context, fn RetryableFunc[T], config *Config) (T, error) {
@@ -40,7 +42,7 @@ func Retry[T any](ctx context.Context, fn RetryableFunc[T], config *Config) (T,
 
 		lastErr = err
 
-		if !config.RetryableChecker(err) {
+		if isPermanent(err) || !config.RetryableChecker(err) {
 			return zero, err
 		}
 
@@ -62,6 +64,17 @@ func Retry[T any](ctx context.Context, fn RetryableFunc[T], config *Config) (T,
 	return zero, lastErr
 }
 
+// Permanent - ошибка, которую сама по себе бессмысленно повторять (например, превышение лимитов ответа).
+type Permanent interface {
+	error
+	Permanent() bool
+}
+
+func isPermanent(err error) bool {
+	var permanent Permanent
+	return errors.As(err, &permanent) && permanent.Permanent()
+}
+
 // RetryableFunc запускаемая функция.
 type RetryableFunc[T any] func() (T, error)
 
@@ -2,6 +2,7 @@ package retry
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -82,6 +83,23 @@ func TestRetry(t *testing.T) {
 		}
 	})
 
+	t.Run("permanent_error_should_not_retry", func(t *testing.T) {
+		var retries int
+
+		_, err := Retry[int](t.Context(), func() (int, error) {
+			retries++
+			return 0, fmt.Errorf("wrapped: %w", permanentErr{})
+		}, NewConfig(WithMaxAttempts(3), WithMaxDelay(100*time.Millisecond)))
+
+		if err == nil {
+			t.Fatalf("want error")
+		}
+
+		if retries != 1 {
+			t.Fatalf("got %v retries, want 1", retries)
+		}
+	})
+
 	t.Run("jitter", func(t *testing.T) {
 		var retries int
 		var elapsed time.Duration
@@ -158,3 +176,8 @@ func TestRetry(t *testing.T) {
 		}
 	})
 }
+
+type permanentErr struct{}
+
+func (permanentErr) Error() string   { return "permanent" }
+func (permanentErr) Permanent() bool { return true }
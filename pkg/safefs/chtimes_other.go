@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package safefs
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// chtimes without futimes goes by the path, the checks before it are the only protection.
+func chtimes(f *os.File, atime, mtime time.Time) error {
+	return os.Chtimes(f.Name(), atime, mtime)
+}
+
+// linkCount is unknown, hardlinks are not detected.
+func linkCount(fs.FileInfo) uint64 {
+	return 1
+}
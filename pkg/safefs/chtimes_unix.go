@@ -0,0 +1,23 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package safefs
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+// chtimes меняет время через дескриптор: путь мог быть подменен после проверок.
+func chtimes(f *os.File, atime, mtime time.Time) error {
+	tv := []syscall.Timeval{syscall.NsecToTimeval(atime.UnixNano()), syscall.NsecToTimeval(mtime.UnixNano())}
+	return os.NewSyscallError("futimes", syscall.Futimes(int(f.Fd()), tv))
+}
+
+func linkCount(fi fs.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
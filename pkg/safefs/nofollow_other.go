@@ -0,0 +1,6 @@
+//go:build !unix
+
+package safefs
+
+// oNoFollow is not supported, os.Root and Lstat checks are the only protection.
+const oNoFollow = 0
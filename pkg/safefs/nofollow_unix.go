@@ -0,0 +1,7 @@
+//go:build unix
+
+package safefs
+
+import "syscall"
+
+const oNoFollow = syscall.O_NOFOLLOW
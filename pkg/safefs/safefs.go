@@ -0,0 +1,388 @@
+// Package safefs пишет файлы только внутри корневой директории.
+//
+// Пути приходят из удаленных url, поэтому каждый путь проверяется до записи (Clean),
+// а сама запись идет через os.Root: он не дает выйти за пределы корня ни через "..", ни через symlink.
+// Дополнительно запись не идет через symlinks внутри корня (проверка Lstat + O_NOFOLLOW), а сами symlinks создает только Link.
+package safefs
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathlib "path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PathError - путь отклонен как небезопасный, повторять запись нет смысла.
+type PathError struct {
+	Path   string
+	Reason string
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("unsafe path %q: %s", e.Path, e.Reason)
+}
+
+type Root struct {
+	dir  string
+	root *os.Root
+	// linkDirs - директории, symlinks на файлы которых читает ReadFile (см. FollowLinksInto)
+	linkDirs []string
+}
+
+// Open открывает (и создает при необходимости) корневую директорию.
+func Open(dir string) (*Root, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open root: %w", err)
+	}
+
+	return &Root{dir: dir, root: root}, nil
+}
+
+func (r *Root) Close() error {
+	return r.root.Close()
+}
+
+// FollowLinksInto разрешает ReadFile читать symlinks, созданные Link, на файлы внутри dir (например, общее
+// хранилище blobs). Остальные symlinks не читаются. Вызывается до начала работы с корнем.
+func (r *Root) FollowLinksInto(dir string) error {
+	rel, err := Clean(dir)
+	if err != nil {
+		return err
+	}
+
+	r.linkDirs = append(r.linkDirs, rel)
+	return nil
+}
+
+// Dir возвращает корневую директорию.
+func (r *Root) Dir() string {
+	return r.dir
+}
+
+// Clean проверяет путь относительно корня и приводит его к виду "a/b/c.html".
+// Ведущий "/" допускается и означает корень, ".." и абсолютные пути других видов отклоняются.
+func Clean(name string) (string, error) {
+	switch {
+	case strings.ContainsRune(name, 0):
+		return "", &PathError{Path: name, Reason: "contains NUL byte"}
+	case strings.ContainsRune(name, '\\'):
+		return "", &PathError{Path: name, Reason: "contains backslash"}
+	}
+
+	rel := strings.TrimLeft(name, "/")
+	if filepath.VolumeName(filepath.FromSlash(rel)) != "" || filepath.IsAbs(filepath.FromSlash(rel)) {
+		return "", &PathError{Path: name, Reason: "absolute path"}
+	}
+
+	for _, segment := range strings.Split(rel, "/") {
+		if segment == ".." {
+			return "", &PathError{Path: name, Reason: "parent directory reference"}
+		}
+	}
+
+	rel = pathlib.Clean(rel)
+	if rel == "." || rel == "" {
+		return "", &PathError{Path: name, Reason: "empty path"}
+	}
+
+	return rel, nil
+}
+
+// WriteFile создает промежуточные директории и пишет файл, не следуя symlinks.
+// Файл пишется во временный и переименовывается: при ошибке прежний файл остается целым, а жесткая ссылка
+// на общий blob (см. Link) заменяется, а не перезаписывается.
+func (r *Root) WriteFile(name string, data []byte, perm os.FileMode) error {
+	rel, err := Clean(name)
+	if err != nil {
+		return err
+	}
+
+	if err := r.mkdirAll(pathlib.Dir(rel)); err != nil {
+		return err
+	}
+
+	if err := r.checkNotSymlink(rel); err != nil {
+		return err
+	}
+
+	// concurrent writes of the same file don't share the temporary one
+	tmpRel := pathlib.Join(pathlib.Dir(rel), "."+pathlib.Base(rel)+"."+rand.Text()+".tmp")
+	f, err := r.root.OpenFile(tmpRel, os.O_WRONLY|os.O_CREATE|os.O_EXCL|oNoFollow, perm)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// os.Root.Rename appeared only in go1.25, the directories are checked by mkdirAll
+		err = os.Rename(r.fullPath(tmpRel), r.fullPath(rel))
+	}
+	if err != nil {
+		_ = r.root.Remove(tmpRel)
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
+
+// ReadFile читает файл внутри корня, не следуя symlinks. Исключение - symlinks в директории из FollowLinksInto.
+func (r *Root) ReadFile(name string) ([]byte, error) {
+	rel, err := Clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := r.lstatNoSymlinks(rel)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		if rel, err = r.linkTarget(rel); err != nil {
+			return nil, err
+		}
+		if _, err := r.lstatNoSymlinks(rel); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := r.root.OpenFile(rel, os.O_RDONLY|oNoFollow, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// Chtimes меняет время доступа и изменения файла внутри корня через открытый файл, не следуя symlinks
+// (os.Root.Chtimes появился только в go1.25). Файл с несколькими жесткими ссылками (общий blob, см. Link)
+// не меняется: его mtime один на все ссылки.
+func (r *Root) Chtimes(name string, atime, mtime time.Time) error {
+	rel, err := Clean(name)
+	if err != nil {
+		return err
+	}
+
+	fi, err := r.lstatNoSymlinks(rel)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		return &PathError{Path: rel, Reason: "is a symlink"}
+	}
+
+	f, err := r.root.OpenFile(rel, os.O_RDONLY|oNoFollow, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if fi, err = f.Stat(); err != nil {
+		return err
+	}
+	if linkCount(fi) > 1 {
+		return nil
+	}
+
+	return chtimes(f, atime, mtime)
+}
+
+// Remove удаляет файл или пустую директорию внутри корня.
+func (r *Root) Remove(name string) error {
+	rel, err := Clean(name)
+	if err != nil {
+		return err
+	}
+
+	return r.root.Remove(rel)
+}
+
+// Rename переносит файл внутри корня, создавая директории для newname.
+// os.Root.Rename появился только в go1.25, поэтому каждый сегмент пути проверяется на symlink перед os.Rename.
+func (r *Root) Rename(oldname, newname string) error {
+	oldRel, err := Clean(oldname)
+	if err != nil {
+		return err
+	}
+	newRel, err := Clean(newname)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkNoSymlinks(oldRel); err != nil {
+		return err
+	}
+	if err := r.mkdirAll(pathlib.Dir(newRel)); err != nil {
+		return err
+	}
+	if err := r.checkNotSymlink(newRel); err != nil {
+		return err
+	}
+
+	return os.Rename(r.fullPath(oldRel), r.fullPath(newRel))
+}
+
+// Link заменяет name ссылкой на существующий файл target внутри корня: жесткой или symlink с относительным путем.
+func (r *Root) Link(target, name string, symbolic bool) error {
+	targetRel, err := Clean(target)
+	if err != nil {
+		return err
+	}
+	rel, err := Clean(name)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkNoSymlinks(targetRel); err != nil {
+		return err
+	}
+	if err := r.mkdirAll(pathlib.Dir(rel)); err != nil {
+		return err
+	}
+
+	// an existing link or file is replaced, removing a symlink does not touch its target
+	fi, err := r.root.Lstat(rel)
+	switch {
+	case err == nil && fi.IsDir():
+		return fmt.Errorf("%q is a directory", rel)
+	case err == nil:
+		if err := r.root.Remove(rel); err != nil {
+			return fmt.Errorf("replace file: %w", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	if !symbolic {
+		return os.Link(r.fullPath(targetRel), r.fullPath(rel))
+	}
+
+	linkTarget, err := filepath.Rel(filepath.Dir(r.fullPath(rel)), r.fullPath(targetRel))
+	if err != nil {
+		return err
+	}
+	return os.Symlink(linkTarget, r.fullPath(rel))
+}
+
+// Stat возвращает сведения о файле, не следуя symlink.
+func (r *Root) Stat(name string) (fs.FileInfo, error) {
+	rel, err := Clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.root.Lstat(rel)
+}
+
+// lstatNoSymlinks проверяет, что в директориях пути нет symlinks, и возвращает сведения о самом файле.
+func (r *Root) lstatNoSymlinks(rel string) (fs.FileInfo, error) {
+	if dir := pathlib.Dir(rel); dir != "." {
+		if err := r.checkNoSymlinks(dir); err != nil {
+			return nil, err
+		}
+	}
+	return r.root.Lstat(rel)
+}
+
+// linkTarget возвращает путь, на который указывает symlink rel, если он внутри одной из linkDirs.
+func (r *Root) linkTarget(rel string) (string, error) {
+	// os.Root.Readlink appeared only in go1.25
+	target, err := os.Readlink(r.fullPath(rel))
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(target) {
+		return "", &PathError{Path: rel, Reason: "symlink to an absolute path"}
+	}
+
+	targetRel, err := Clean(pathlib.Join(pathlib.Dir(rel), filepath.ToSlash(target)))
+	if err != nil {
+		return "", &PathError{Path: rel, Reason: "symlink outside of the root"}
+	}
+	for _, dir := range r.linkDirs {
+		if strings.HasPrefix(targetRel, dir+"/") {
+			return targetRel, nil
+		}
+	}
+	return "", &PathError{Path: rel, Reason: "is a symlink"}
+}
+
+// checkNoSymlinks проверяет каждый сегмент существующего пути.
+func (r *Root) checkNoSymlinks(rel string) error {
+	current := ""
+	for _, segment := range strings.Split(rel, "/") {
+		current = pathlib.Join(current, segment)
+
+		fi, err := r.root.Lstat(current)
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return &PathError{Path: current, Reason: "symlink in path"}
+		}
+	}
+	return nil
+}
+
+func (r *Root) fullPath(rel string) string {
+	return filepath.Join(r.dir, filepath.FromSlash(rel))
+}
+
+func (r *Root) checkNotSymlink(rel string) error {
+	fi, err := r.root.Lstat(rel)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case fi.Mode()&fs.ModeSymlink != 0:
+		return &PathError{Path: rel, Reason: "is a symlink"}
+	case fi.IsDir():
+		return fmt.Errorf("%q is a directory", rel)
+	}
+	return nil
+}
+
+func (r *Root) mkdirAll(dir string) error {
+	if dir == "." {
+		return nil
+	}
+
+	current := ""
+	for _, segment := range strings.Split(dir, "/") {
+		current = pathlib.Join(current, segment)
+
+		fi, err := r.root.Lstat(current)
+		switch {
+		case err == nil && fi.Mode()&fs.ModeSymlink != 0:
+			return &PathError{Path: current, Reason: "symlink in path"}
+		case err == nil && !fi.IsDir():
+			return fmt.Errorf("create directory %q: not a directory", current)
+		case err == nil:
+			continue
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+
+		// another worker could create it concurrently
+		if err := r.root.Mkdir(current, 0755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("create directory: %w", err)
+		}
+	}
+
+	return nil
+}
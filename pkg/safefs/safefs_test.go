@@ -0,0 +1,260 @@
+package safefs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRoot создает root и соседнюю директорию outside, на которую внутри root указывает symlink "link".
+func newTestRoot(t testing.TB) (*Root, string) {
+	base := t.TempDir()
+	outside := filepath.Join(base, "outside")
+
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := Open(filepath.Join(base, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = root.Close() })
+
+	if err := os.Symlink(outside, filepath.Join(root.Dir(), "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "file"), filepath.Join(root.Dir(), "file-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	return root, outside
+}
+
+func TestWriteFile(t *testing.T) {
+	root, _ := newTestRoot(t)
+
+	t.Run("nested", func(t *testing.T) {
+		if err := root.WriteFile("/a/b/c.html", []byte("ok"), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := root.ReadFile("a/b/c.html")
+		if err != nil || string(got) != "ok" {
+			t.Errorf("got %q, %v", got, err)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		if err := root.WriteFile("a/b/c.html", []byte("new"), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, err := root.ReadFile("a/b/c.html"); err != nil || string(got) != "new" {
+			t.Errorf("got %q, %v", got, err)
+		}
+		// the temporary file is renamed
+		if entries, _ := os.ReadDir(filepath.Join(root.Dir(), "a", "b")); len(entries) != 1 {
+			t.Errorf("got %d files, want 1", len(entries))
+		}
+	})
+
+	rejected := []string{
+		"../outside/x",
+		"a/../../outside/x",
+		"link/x",
+		"file-link",
+		`a\..\..\x`,
+		"a\x00b",
+		"",
+		"/",
+	}
+
+	for _, name := range rejected {
+		t.Run("reject_"+name, func(t *testing.T) {
+			err := root.WriteFile(name, []byte("evil"), 0644)
+
+			var pathErr *PathError
+			if !errors.As(err, &pathErr) {
+				t.Errorf("want *PathError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestChtimes(t *testing.T) {
+	root, outside := newTestRoot(t)
+
+	if err := root.WriteFile("a/b.html", []byte("ok"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "file"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	if err := root.Chtimes("a/b.html", mtime, mtime); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(root.Dir(), "a", "b.html"))
+	if err != nil || !fi.ModTime().Equal(mtime) {
+		t.Errorf("got mtime %v, %v, want %v", fi.ModTime(), err, mtime)
+	}
+
+	for _, name := range []string{"file-link", "link/file", "../outside/file"} {
+		var pathErr *PathError
+		if err := root.Chtimes(name, mtime, mtime); !errors.As(err, &pathErr) {
+			t.Errorf("%s: want *PathError, got %v", name, err)
+		}
+	}
+
+	// a shared file keeps its mtime
+	if err := root.WriteFile(".blobs/x", []byte("shared"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Link(".blobs/x", "a/x.js", false); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(filepath.Join(root.Dir(), ".blobs", "x"))
+	if err := root.Chtimes("a/x.js", mtime, mtime); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if after, _ := os.Stat(filepath.Join(root.Dir(), ".blobs", "x")); !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("shared file mtime is changed to %v", after.ModTime())
+	}
+}
+
+func TestRenameRemove(t *testing.T) {
+	root, _ := newTestRoot(t)
+
+	if err := root.WriteFile("a/b.html", []byte("ok"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := root.Rename("a/b.html", "q/a/b.html"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := root.ReadFile("q/a/b.html"); err != nil || string(got) != "ok" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if err := root.Remove("a"); err != nil {
+		t.Errorf("empty directory is not removed: %v", err)
+	}
+	if err := root.Remove("q"); err == nil {
+		t.Error("not empty directory is removed")
+	}
+
+	var pathErr *PathError
+	if err := root.Rename("q/a/b.html", "link/b.html"); !errors.As(err, &pathErr) {
+		t.Errorf("want *PathError, got %v", err)
+	}
+	if err := root.Rename("file-link", "x"); !errors.As(err, &pathErr) {
+		t.Errorf("want *PathError, got %v", err)
+	}
+}
+
+func TestLink(t *testing.T) {
+	root, outside := newTestRoot(t)
+	if err := root.FollowLinksInto(".blobs"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(outside, "file"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.WriteFile(".blobs/x", []byte("shared"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, symbolic := range []bool{false, true} {
+		name := "a/hard.js"
+		if symbolic {
+			name = "a/b/sym.js"
+		}
+
+		// an existing file is replaced by the link
+		if err := root.WriteFile(name, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.Link(".blobs/x", name, symbolic); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, err := root.ReadFile(name); err != nil || string(got) != "shared" {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+
+	// writing to a hardlink does not change the shared file
+	if err := root.WriteFile("a/hard.js", []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := root.ReadFile(".blobs/x"); string(got) != "shared" {
+		t.Errorf("shared file is changed: %q", got)
+	}
+
+	var pathErr *PathError
+	if err := root.Link("file-link", "a/x", true); !errors.As(err, &pathErr) {
+		t.Errorf("want *PathError, got %v", err)
+	}
+	if _, err := root.ReadFile("file-link"); err == nil {
+		t.Error("symlink outside of the root is read")
+	}
+
+	// only links to the shared files are followed
+	if err := root.WriteFile("private", []byte("private"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Link("private", "a/private-link", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.ReadFile("a/private-link"); !errors.As(err, &pathErr) {
+		t.Errorf("want *PathError, got %v", err)
+	}
+	if _, err := root.ReadFile("link/file"); !errors.As(err, &pathErr) {
+		t.Errorf("want *PathError for a symlink in path, got %v", err)
+	}
+}
+
+// FuzzWriteFile: что бы ни пришло в качестве пути, запись либо отклоняется, либо происходит внутри root.
+func FuzzWriteFile(f *testing.F) {
+	for _, seed := range []string{"index.html", "a/b/c.css", "/abs.html", "../x", "link/x", "a/./b//c"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		root, outside := newTestRoot(t)
+
+		err := root.WriteFile(name, []byte("data"), 0644)
+
+		entries, readErr := os.ReadDir(outside)
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("%q: written outside of the root: %v", name, entries)
+		}
+
+		if err != nil {
+			return
+		}
+
+		rel, cleanErr := Clean(name)
+		if cleanErr != nil {
+			t.Fatalf("%q: written, but Clean rejects it: %v", name, cleanErr)
+		}
+
+		resolved, evalErr := filepath.EvalSymlinks(filepath.Join(root.Dir(), filepath.FromSlash(rel)))
+		if evalErr != nil {
+			t.Fatalf("%q: written file not found: %v", name, evalErr)
+		}
+
+		rootDir, _ := filepath.EvalSymlinks(root.Dir())
+		if !strings.HasPrefix(resolved, rootDir+string(filepath.Separator)) {
+			t.Fatalf("%q: written to %q, outside of %q", name, resolved, rootDir)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+// Package simhash implements 64-bit SimHash fingerprints (Charikar): similar texts get fingerprints
+// which differ in a few bits, so near-duplicates are found by the Hamming distance.
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize - признаки текста - последовательности из стольких слов: так учитывается и порядок слов.
+const shingleSize = 3
+
+// Fingerprint возвращает отпечаток текста. Регистр, пунктуация и пробелы не учитываются, пустой текст - 0.
+func Fingerprint(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	// a short text is a single shingle
+	size := min(shingleSize, len(words))
+
+	var weights [64]int
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		feature := mix(h.Sum64())
+
+		for bit := range weights {
+			if feature&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// mix - финализатор MurmurHash3: у FNV биты соседних по содержимому строк коррелируют, а для SimHash
+// каждый бит признака должен быть независимым.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Distance - число различающихся битов.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Index ищет среди добавленных отпечатков ближайший на расстоянии не больше maxDistance.
+//
+// Отпечаток делится на maxDistance+1 блоков: у отпечатков, различающихся не больше чем в maxDistance битах,
+// хотя бы один блок совпадает, поэтому сравниваются только отпечатки с совпавшим блоком.
+// Index не безопасен для конкурентного использования.
+type Index struct {
+	maxDistance int
+	entries     []entry
+	// tables[i] - номера entries по значению i-го блока
+	tables []map[uint64][]int
+}
+
+type entry struct {
+	id          string
+	fingerprint uint64
+}
+
+// NewIndex создает индекс, maxDistance - от 0 до 63.
+func NewIndex(maxDistance int) *Index {
+	maxDistance = max(0, min(maxDistance, 63))
+
+	tables := make([]map[uint64][]int, maxDistance+1)
+	for i := range tables {
+		tables[i] = map[uint64][]int{}
+	}
+	return &Index{maxDistance: maxDistance, tables: tables}
+}
+
+func (x *Index) Add(id string, fingerprint uint64) {
+	n := len(x.entries)
+	x.entries = append(x.entries, entry{id: id, fingerprint: fingerprint})
+
+	for i, table := range x.tables {
+		block := x.block(fingerprint, i)
+		table[block] = append(table[block], n)
+	}
+}
+
+// Nearest возвращает id ближайшего отпечатка, из равноудаленных - добавленного первым.
+func (x *Index) Nearest(fingerprint uint64) (string, int, bool) {
+	best, bestDistance := -1, x.maxDistance+1
+	for i, table := range x.tables {
+		for _, n := range table[x.block(fingerprint, i)] {
+			distance := Distance(fingerprint, x.entries[n].fingerprint)
+			if distance < bestDistance || (distance == bestDistance && n < best) {
+				best, bestDistance = n, distance
+			}
+		}
+	}
+
+	if best < 0 {
+		return "", 0, false
+	}
+	return x.entries[best].id, bestDistance, true
+}
+
+func (x *Index) block(fingerprint uint64, i int) uint64 {
+	blocks := len(x.tables)
+	from, to := i*64/blocks, (i+1)*64/blocks
+	// a single block is the whole fingerprint: 1<<64 overflows to 0, the mask is all ones
+	return (fingerprint >> from) & (1<<(to-from) - 1)
+}
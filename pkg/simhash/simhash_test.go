@@ -0,0 +1,71 @@
+package simhash
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// article - текст обычной длины статьи, на коротких текстах расстояние зависит от случайных совпадений.
+var article = func() string {
+	var b strings.Builder
+	for i := range 60 {
+		fmt.Fprintf(&b, "Section %d of the guide explains how step %d of the crawl works and why option %d matters. ", i, i*7, i*13)
+	}
+	return b.String()
+}()
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		maxDistance int
+		minDistance int
+	}{
+		{"same text", article, 0, 0},
+		{"case and spaces", strings.ToUpper(strings.Join(strings.Fields(article), "  ")), 0, 0},
+		{"one word changed", strings.Replace(article, "crawl", "download", 1), 3, 0},
+		{"sentence appended", article + " Rendered at 12:00 by server 7.", 3, 0},
+		{"another text", "Release notes: the downloader retries requests, limits the rate and keeps cookies between runs of the tool.", 64, 12},
+	}
+
+	fingerprint := Fingerprint(article)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance := Distance(fingerprint, Fingerprint(tt.text))
+			if distance > tt.maxDistance || distance < tt.minDistance {
+				t.Errorf("got distance %d, want %d..%d", distance, tt.minDistance, tt.maxDistance)
+			}
+		})
+	}
+
+	if got := Fingerprint(" ... "); got != 0 {
+		t.Errorf("got %x for an empty text", got)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	for _, maxDistance := range []int{0, 3, 63} {
+		t.Run(fmt.Sprint(maxDistance), func(t *testing.T) {
+			x := NewIndex(maxDistance)
+			x.Add("a", 0b1111)
+			x.Add("b", 0b1111<<60)
+			x.Add("c", 0b1111)
+
+			// every fingerprint within maxDistance is found, even if all blocks but one differ
+			for distance := 0; distance <= 4; distance++ {
+				fingerprint := uint64(0b1111) ^ (1<<distance - 1)
+				id, got, ok := x.Nearest(fingerprint)
+
+				wantOK := distance <= maxDistance
+				if ok != wantOK || (ok && (id != "a" || got != distance)) {
+					t.Errorf("distance %d: got %q, %d, %t", distance, id, got, ok)
+				}
+			}
+
+			if id, _, ok := x.Nearest(0b1011 << 60); maxDistance >= 1 && (!ok || id != "b") {
+				t.Errorf("got %q, %t, want b", id, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package sri implements Subresource Integrity (https://www.w3.org/TR/SRI/) digests.
+package sri
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Digest - одно значение атрибута integrity, например "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC".
+type Digest struct {
+	Algorithm string
+	Value     string
+}
+
+func (d Digest) String() string {
+	return d.Algorithm + "-" + d.Value
+}
+
+// algorithms в порядке возрастания стойкости.
+var algorithms = []string{"sha256", "sha384", "sha512"}
+
+// Parse разбирает значение атрибута integrity, неподдерживаемые алгоритмы и опции ("?...") игнорируются.
+func Parse(integrity string) []Digest {
+	var res []Digest
+
+	for _, token := range strings.Fields(integrity) {
+		token, _, _ = strings.Cut(token, "?")
+
+		alg, value, ok := strings.Cut(token, "-")
+		if !ok || value == "" || strength(alg) < 0 {
+			continue
+		}
+
+		res = append(res, Digest{Algorithm: alg, Value: value})
+	}
+
+	return res
+}
+
+// Compute считает digest содержимого заданным алгоритмом.
+func Compute(algorithm string, content []byte) (Digest, error) {
+	var h hash.Hash
+
+	switch algorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return Digest{}, fmt.Errorf("unsupported integrity algorithm %q", algorithm)
+	}
+
+	h.Write(content)
+
+	return Digest{Algorithm: algorithm, Value: base64.StdEncoding.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Strongest возвращает самый стойкий алгоритм из списка, пустую строку для пустого списка.
+func Strongest(digests []Digest) string {
+	var res string
+	for _, d := range digests {
+		if strength(d.Algorithm) > strength(res) {
+			res = d.Algorithm
+		}
+	}
+	return res
+}
+
+// Verify проверяет содержимое так же, как это делает браузер:
+// учитываются только digests самого стойкого алгоритма, достаточно совпадения с любым из них.
+// Значение без поддерживаемых digests считается валидным.
+func Verify(integrity string, content []byte) bool {
+	digests := Parse(integrity)
+	if len(digests) == 0 {
+		return true
+	}
+
+	alg := Strongest(digests)
+
+	actual, err := Compute(alg, content)
+	if err != nil {
+		return false
+	}
+
+	for _, d := range digests {
+		if d.Algorithm != alg {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(d.Value), []byte(actual.Value)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func strength(algorithm string) int {
+	for i, alg := range algorithms {
+		if alg == algorithm {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,48 @@
+package sri
+
+import "testing"
+
+func TestVerify(t *testing.T) {
+	content := []byte("alert('Hello, world.');")
+
+	// echo -n "alert('Hello, world.');" | openssl dgst -sha384 -binary | openssl base64 -A
+	valid := "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO"
+
+	tests := []struct {
+		name      string
+		integrity string
+		want      bool
+	}{
+		{"valid", valid, true},
+		{"valid_with_options", valid + "?foo=bar", true},
+		{"invalid", "sha384-AAAAh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO", false},
+		{"weaker_invalid_ignored", "sha256-AAAA " + valid, true},
+		{"stronger_invalid_wins", valid + " sha512-AAAA", false},
+		{"unsupported_only", "md5-AAAA", true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.integrity, content); got != tt.want {
+				t.Errorf("Verify(%q) = %v, want %v", tt.integrity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	d, err := Compute("sha256", []byte(""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	if d.String() != want {
+		t.Errorf("got %q, want %q", d.String(), want)
+	}
+
+	if _, err := Compute("md5", nil); err == nil {
+		t.Errorf("want error for unsupported algorithm")
+	}
+}